@@ -10,9 +10,12 @@ import (
 	"maps"
 	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
 	"strings"
 	"text/template"
 
+	"github.com/Masterminds/semver/v3"
 	"github.com/pelletier/go-toml/v2"
 )
 
@@ -40,129 +43,334 @@ func (d *Deno) Match(path string) bool {
 		}
 	}
 
-	detected := false
-	// Walk the directory to find a .ts file with a "deno.land/x" import
-	filepath.WalkDir(path, func(path string, info fs.DirEntry, err error) error {
+	if file := findDenoImport(path); file != "" {
+		d.Log.Info("Detected Deno project via imports in " + file)
+		return true
+	}
+
+	d.Log.Debug("Deno project not detected")
+	return false
+}
+
+// Limits on the source scan in Match, which runs for every project before its
+// runtime is known
+const (
+	denoScanMaxDepth = 4
+	denoScanMaxFiles = 1000
+)
+
+// Dependency and build directories that never contain the project's own source
+var denoScanSkipDirs = []string{"node_modules", "vendor", "bower_components", "dist", "build", "target"}
+
+// Matches https://deno.land/, jsr: and npm: specifiers in static and dynamic imports
+var denoImportRe = regexp.MustCompile(`(?:^\s*import\s*|\bfrom\s*|\bimport\s*\(\s*)["'](?:https://deno\.land/|jsr:|npm:)`)
+
+// Returns the first TypeScript file, relative to path, that imports a Deno
+// specifier. Files ignored by .gitignore or .dockerignore are skipped.
+func findDenoImport(path string) string {
+	ignore := readIgnorePatterns(path)
+	found := ""
+	scanned := 0
+	filepath.WalkDir(path, func(fp string, info fs.DirEntry, err error) error {
 		if err != nil {
-			return err
+			return nil
 		}
 
-		if !info.IsDir() && filepath.Ext(path) == ".ts" {
-			f, err := os.Open(path)
-			if err != nil {
-				return err
+		rel, err := filepath.Rel(path, fp)
+		if err != nil || rel == "." {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+
+		if info.IsDir() {
+			if strings.HasPrefix(info.Name(), ".") || slices.Contains(denoScanSkipDirs, info.Name()) || strings.Count(rel, "/") >= denoScanMaxDepth || ignore.matches(rel, true) {
+				return filepath.SkipDir
 			}
+			return nil
+		}
 
-			defer f.Close()
-			scanner := bufio.NewScanner(f)
-			for scanner.Scan() {
-				text := scanner.Text()
-
-				if (strings.HasPrefix(text, "import ") || strings.HasPrefix(text, "export ")) && strings.Contains(text, " from ") && strings.Contains(text, "https://deno.land/") {
-					d.Log.Info("Detected Deno project")
-					detected = true
-					return filepath.SkipAll
-				}
+		if !slices.Contains([]string{".ts", ".tsx", ".mts"}, filepath.Ext(fp)) || ignore.matches(rel, false) {
+			return nil
+		}
+
+		if scanned++; scanned > denoScanMaxFiles {
+			return filepath.SkipAll
+		}
+
+		f, err := os.Open(fp)
+		if err != nil {
+			return nil
+		}
+
+		defer f.Close()
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			if denoImportRe.MatchString(scanner.Text()) {
+				found = rel
+				return filepath.SkipAll
 			}
 		}
 
 		return nil
 	})
 
-	d.Log.Debug("Deno project not detected")
-	return detected
+	return found
 }
 
-func (d *Deno) GenerateDockerfile(path string, data ...map[string]string) ([]byte, error) {
-	tmpl, err := template.New("Dockerfile").Parse(denoTemplate)
-	if err != nil {
-		return nil, fmt.Errorf("Failed to parse template")
-	}
+type ignorePattern struct {
+	re      *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
 
-	var denoJSON map[string]interface{}
-	configFiles := []string{"deno.jsonc", "deno.json"}
-	for _, file := range configFiles {
+type ignorePatterns []ignorePattern
+
+// Reads the patterns in the .gitignore and .dockerignore files at the root of path
+func readIgnorePatterns(path string) ignorePatterns {
+	var patterns ignorePatterns
+	for _, file := range []string{".gitignore", ".dockerignore"} {
 		f, err := os.Open(filepath.Join(path, file))
 		if err != nil {
 			continue
 		}
 
-		defer f.Close()
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
 
-		if err := json.NewDecoder(f).Decode(&denoJSON); err != nil {
-			return nil, fmt.Errorf("Failed to decode " + file + " file")
+			pattern := ignorePattern{}
+			if strings.HasPrefix(line, "!") {
+				pattern.negate = true
+				line = line[1:]
+			}
+			if strings.HasSuffix(line, "/") {
+				pattern.dirOnly = true
+				line = strings.TrimSuffix(line, "/")
+			}
+
+			// .dockerignore patterns are relative to the root, while .gitignore
+			// patterns without a slash match at any depth
+			anchored := file == ".dockerignore" || strings.Contains(line, "/")
+			line = strings.TrimPrefix(line, "/")
+			if line == "" {
+				continue
+			}
+
+			expr := globToRegexp(line)
+			if !anchored {
+				expr = "(?:.*/)?" + expr
+			}
+			re, err := regexp.Compile("^" + expr + "$")
+			if err != nil {
+				continue
+			}
+
+			pattern.re = re
+			patterns = append(patterns, pattern)
 		}
 
 		f.Close()
-		break
+	}
+
+	return patterns
+}
+
+// Reports whether a slash-separated path relative to the root is ignored. Later
+// patterns take precedence, so a negated pattern re-includes a path.
+func (p ignorePatterns) matches(rel string, isDir bool) bool {
+	ignored := false
+	for _, pattern := range p {
+		if pattern.dirOnly && !isDir {
+			continue
+		}
+
+		if pattern.re.MatchString(rel) {
+			ignored = !pattern.negate
+		}
+	}
+
+	return ignored
+}
+
+// Converts a gitignore-style glob to a regular expression
+func globToRegexp(glob string) string {
+	var expr strings.Builder
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; c {
+		case '*':
+			if strings.HasPrefix(glob[i:], "**/") {
+				expr.WriteString("(?:.*/)?")
+				i += 2
+			} else if strings.HasPrefix(glob[i:], "**") {
+				expr.WriteString(".*")
+				i++
+			} else {
+				expr.WriteString("[^/]*")
+			}
+		case '?':
+			expr.WriteString("[^/]")
+		case '[':
+			if end := strings.IndexByte(glob[i:], ']'); end > 0 {
+				class := glob[i+1 : i+end]
+				if strings.HasPrefix(class, "!") {
+					class = "^" + class[1:]
+				}
+				expr.WriteString("[" + class + "]")
+				i += end
+			} else {
+				expr.WriteString(regexp.QuoteMeta(string(c)))
+			}
+		case '\\':
+			if i+1 < len(glob) {
+				i++
+				expr.WriteString(regexp.QuoteMeta(string(glob[i])))
+			}
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+
+	return expr.String()
+}
+
+func (d *Deno) GenerateDockerfile(path string, data ...map[string]string) ([]byte, error) {
+	tmpl, err := template.New("Dockerfile").Parse(denoTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse template")
+	}
+
+	config, err := readDenoConfig(path)
+	if err != nil {
+		return nil, err
+	}
+
+	version, err := findDenoVersion(path, d.Log)
+	if err != nil {
+		return nil, err
+	}
+
+	mode := "run"
+	if len(data) > 0 && data[0]["Mode"] != "" {
+		mode = data[0]["Mode"]
+	}
+	if mode != "run" && mode != "compile" {
+		return nil, fmt.Errorf("Failed to select Deno mode: %s is not one of run or compile", mode)
 	}
 
 	var startCMD string
 	var installCMD string
+	var buildCMD string
 
-	scripts, ok := denoJSON["tasks"].(map[string]interface{})
-	if ok {
+	fresh := isFreshProject(path, config)
+	if fresh {
+		d.Log.Info("Detected Fresh project")
+	}
+
+	if config.Tasks != nil {
 		startCommands := []string{"serve", "start:prod", "start:production", "start-prod", "start-production", "preview", "start"}
 		for _, cmd := range startCommands {
-			if _, ok := scripts[cmd].(string); ok {
+			task, ok := config.Tasks[cmd]
+			// Fresh 1.x projects start the dev server with "start"
+			if ok && !(fresh && strings.Contains(denoTaskCommand(task), "dev.ts")) {
 				d.Log.Info("Detected start command in deno.json: " + cmd)
 				startCMD = fmt.Sprintf("deno task %s", cmd)
 				break
 			}
 		}
 
-		if _, ok := scripts["cache"].(string); ok {
+		if _, ok := config.Tasks["cache"]; ok {
 			d.Log.Info("Detected install command in deno.json: cache")
 			installCMD = "deno task cache"
 		}
+
+		if _, ok := config.Tasks["build"]; ok {
+			d.Log.Info("Detected build command in deno.json: build")
+			buildCMD = "deno task build"
+		}
 	}
 
-	if startCMD == "" {
-		mainFiles := []string{"mod.ts", "src/mod.ts", "main.ts", "src/main.ts", "index.ts", "src/index.ts"}
-		for _, mainFile := range mainFiles {
-			if _, err := os.Stat(filepath.Join(path, mainFile)); err == nil {
-				d.Log.Info("Detected start command via main/mod file: " + mainFile)
+	if buildCMD == "" && fresh {
+		if _, err := os.Stat(filepath.Join(path, "dev.ts")); err == nil {
+			d.Log.Info("Detected build command via Fresh dev.ts file")
+			buildCMD = "deno run -A dev.ts build"
+		}
+	}
 
-				startCMD = fmt.Sprintf("deno run --allow-all %s", mainFile)
-				if installCMD == "" {
-					d.Log.Info("Detected install command via main/mod file: " + mainFile)
-					installCMD = "deno cache " + mainFile
-				}
-				break
+	permissions := denoPermissions(path, config)
+	mainFile := findDenoMainFile(path, config)
+	if startCMD == "" && mainFile != "" {
+		d.Log.Info("Detected start command via main/mod file: " + mainFile)
+		startCMD = fmt.Sprintf("deno run %s %s", permissions, mainFile)
+	}
+
+	if installCMD == "" {
+		// Deno 2 replaced deno cache with deno install, which also installs npm
+		// packages from package.json and the imports map
+		frozen := ""
+		if lockFile := denoLockFile(config); lockFile != "" && isDenoVersion(*version, ">=1.45.0-0") {
+			if _, err := os.Stat(filepath.Join(path, lockFile)); err == nil {
+				frozen = "--frozen "
+			}
+		}
+
+		if isDenoVersion(*version, ">=2.0.0-0") {
+			if mainFile != "" {
+				installCMD = fmt.Sprintf("deno install %s--entrypoint %s", frozen, mainFile)
+			} else if config.hasDependencies(path) {
+				installCMD = strings.TrimSpace("deno install " + frozen)
 			}
+		} else if mainFile != "" {
+			installCMD = fmt.Sprintf("deno cache %s%s", frozen, mainFile)
+		}
+
+		if installCMD != "" {
+			d.Log.Info("Detected install command: " + installCMD)
 		}
 	}
 
-	version, err := findDenoVersion(path, d.Log)
-	if err != nil {
-		return nil, err
+	// deno compile embeds the entrypoint's module graph, along with any files
+	// it reads at runtime passed to --include
+	entrypoint := mainFile
+	compileFlags := permissions
+	if fresh {
+		if isFresh2Project(config) {
+			entrypoint = "_fresh/server.js"
+		}
+		compileFlags += " --include _fresh"
+		if _, err := os.Stat(filepath.Join(path, "static")); err == nil {
+			compileFlags += " --include static"
+		}
+	}
+	if mode == "compile" {
+		d.Log.Info("Using deno compile with entrypoint: " + entrypoint)
+		if entrypoint == "" {
+			d.Log.Warn("No entrypoint detected for deno compile. Set the MAIN_FILE build arg")
+		}
 	}
 
 	d.Log.Info(
 		fmt.Sprintf(`Detected defaults
   Version         : %s
   Install command : %s
+  Build command   : %s
   Start command   : %s
 
   Docker build arguments can supersede these defaults if provided.
-  See https://flexstack.com/docs/languages-and-frameworks/autogenerate-dockerfile`, *version, installCMD, startCMD),
+  See https://flexstack.com/docs/languages-and-frameworks/autogenerate-dockerfile`, *version, installCMD, buildCMD, startCMD),
 	)
 
-	if installCMD != "" {
-		installCMDJSON, _ := json.Marshal(installCMD)
-		installCMD = string(installCMDJSON)
-	}
-
-	if startCMD != "" {
-		startCMDJSON, _ := json.Marshal(startCMD)
-		startCMD = string(startCMDJSON)
-	}
-
 	var buf bytes.Buffer
 	templateData := map[string]string{
-		"Version":    *version,
-		"InstallCMD": installCMD,
-		"StartCMD":   startCMD,
+		"Version":      *version,
+		"Mode":         mode,
+		"InstallCMD":   safeCommand(installCMD),
+		"BuildCMD":     safeCommand(buildCMD),
+		"StartCMD":     safeCommand(startCMD),
+		"CompileFlags": safeCommand(compileFlags),
+		"MainFile":     safeCommand(entrypoint),
 	}
 	if len(data) > 0 {
 		maps.Copy(templateData, data[0])
@@ -177,9 +385,25 @@ func (d *Deno) GenerateDockerfile(path string, data ...map[string]string) ([]byt
 var denoTemplate = strings.TrimSpace(`
 ARG VERSION={{.Version}}
 ARG BUILDER=docker.io/denoland/deno
-FROM ${BUILDER}:${VERSION} as base
-
-FROM debian:stable-slim
+ARG MODE={{.Mode}}
+FROM ${BUILDER}:${VERSION} AS build
+WORKDIR /app
+ENV DENO_DIR=.deno_cache
+COPY . .
+ARG INSTALL_CMD={{.InstallCMD}}
+RUN {{.InstallMounts}}if [ ! -z "${INSTALL_CMD}" ]; then sh -c "$INSTALL_CMD"; fi
+ARG BUILD_CMD={{.BuildCMD}}
+RUN {{.BuildMounts}}if [ ! -z "${BUILD_CMD}" ]; then sh -c "$BUILD_CMD"; fi
+
+FROM build AS compile
+ARG TARGETARCH
+ARG COMPILE_FLAGS={{.CompileFlags}}
+ARG MAIN_FILE={{.MainFile}}
+RUN if [ -z "${MAIN_FILE}" ]; then echo "Unable to detect an entrypoint to compile" && exit 1; fi
+RUN case "${TARGETARCH}" in arm64) TARGET=aarch64-unknown-linux-gnu ;; *) TARGET=x86_64-unknown-linux-gnu ;; esac \
+    && deno compile ${COMPILE_FLAGS} --target "${TARGET}" --output /app/server "${MAIN_FILE}"
+
+FROM debian:stable-slim AS runtime-base
 WORKDIR /app
 
 RUN apt-get update && apt-get install -y --no-install-recommends wget ca-certificates && apt-get clean && rm -f /var/lib/apt/lists/*_*
@@ -187,26 +411,271 @@ RUN update-ca-certificates 2>/dev/null || true
 RUN addgroup --system nonroot && adduser --system --ingroup nonroot nonroot
 RUN chown -R nonroot:nonroot /app
 
+FROM runtime-base AS runtime-run
 ENV DENO_DIR=.deno_cache
-RUN mkdir -p /app/${DENO_DIR}
-RUN chown -R nonroot:nonroot /app/${DENO_DIR}
+COPY --chown=nonroot:nonroot --from=build /usr/bin/deno /usr/local/bin/deno
+COPY --chown=nonroot:nonroot --from=build /app .
+ARG START_CMD={{.StartCMD}}
+ENV START_CMD=${START_CMD}
+RUN if [ -z "${START_CMD}" ]; then echo "Unable to detect a container start command" && exit 1; fi
 
-COPY --chown=nonroot:nonroot --from=base /usr/bin/deno /usr/local/bin/deno
-COPY --chown=nonroot:nonroot . .
+FROM runtime-base AS runtime-compile
+COPY --chown=nonroot:nonroot --from=compile /app/server ./server
+ENV START_CMD=/app/server
 
+FROM runtime-${MODE} AS runtime
 USER nonroot:nonroot
 
 ENV PORT=8080
 EXPOSE ${PORT}
-ARG INSTALL_CMD={{.InstallCMD}}
-RUN {{.InstallMounts}}if [ ! -z "${INSTALL_CMD}" ]; then sh -c "$INSTALL_CMD"; fi
-
-ARG START_CMD={{.StartCMD}}
-ENV START_CMD=${START_CMD}
-RUN if [ -z "${START_CMD}" ]; then echo "Unable to detect a container start command" && exit 1; fi
 CMD ${START_CMD}
 `)
 
+type denoConfig struct {
+	Tasks   map[string]any    `json:"tasks"`
+	Imports map[string]string `json:"imports"`
+	// A boolean in Deno 1, or "auto", "manual" or "none" in Deno 2
+	NodeModulesDir any `json:"nodeModulesDir"`
+	// A list of member directories, or an object with a "members" list
+	Workspace any      `json:"workspace"`
+	Unstable  []string `json:"unstable"`
+	// A boolean, a path, or an object with a "path"
+	Lock any `json:"lock"`
+}
+
+// Reads deno.jsonc or deno.json, which may contain comments and trailing commas
+func readDenoConfig(path string) (*denoConfig, error) {
+	config := &denoConfig{}
+	configFiles := []string{"deno.jsonc", "deno.json"}
+	for _, file := range configFiles {
+		b, err := os.ReadFile(filepath.Join(path, file))
+		if err != nil {
+			continue
+		}
+
+		if err := json.Unmarshal(stripJSONC(b), config); err != nil {
+			return nil, fmt.Errorf("Failed to decode " + file + " file")
+		}
+
+		break
+	}
+
+	return config, nil
+}
+
+// Returns the workspace member directories
+func (c *denoConfig) members() []string {
+	var members []any
+	switch workspace := c.Workspace.(type) {
+	case []any:
+		members = workspace
+	case map[string]any:
+		members, _ = workspace["members"].([]any)
+	}
+
+	var dirs []string
+	for _, member := range members {
+		if dir, ok := member.(string); ok {
+			dirs = append(dirs, filepath.ToSlash(filepath.Clean(dir)))
+		}
+	}
+
+	return dirs
+}
+
+// Reports whether the project has dependencies for deno install to fetch
+// without an entrypoint
+func (c *denoConfig) hasDependencies(path string) bool {
+	if len(c.Imports) > 0 || len(c.members()) > 0 {
+		return true
+	}
+
+	_, err := os.Stat(filepath.Join(path, "package.json"))
+	return err == nil
+}
+
+// Reports whether the project uses npm packages
+func (c *denoConfig) usesNPM(path string) bool {
+	for _, specifier := range c.Imports {
+		if strings.HasPrefix(specifier, "npm:") {
+			return true
+		}
+	}
+
+	switch dir := c.NodeModulesDir.(type) {
+	case bool:
+		if dir {
+			return true
+		}
+	case string:
+		if dir != "none" {
+			return true
+		}
+	}
+
+	_, err := os.Stat(filepath.Join(path, "package.json"))
+	return err == nil
+}
+
+func denoLockFile(config *denoConfig) string {
+	switch lock := config.Lock.(type) {
+	case bool:
+		if !lock {
+			return ""
+		}
+	case string:
+		return lock
+	case map[string]any:
+		if p, ok := lock["path"].(string); ok {
+			return p
+		}
+	}
+
+	return "deno.lock"
+}
+
+// Returns the permission flags a server needs, rather than --allow-all
+func denoPermissions(path string, config *denoConfig) string {
+	flags := []string{"--allow-net", "--allow-env", "--allow-read"}
+	if config.usesNPM(path) {
+		// Node.js compatibility reads OS information, e.g. os.cpus()
+		flags = append(flags, "--allow-sys")
+	}
+
+	if slices.Contains(config.Unstable, "kv") {
+		flags = append(flags, "--allow-write")
+	}
+
+	if slices.Contains(config.Unstable, "ffi") {
+		flags = append(flags, "--allow-ffi")
+	}
+
+	return strings.Join(flags, " ")
+}
+
+// Returns the main/module file of the project, or of the first workspace member
+// that has one
+func findDenoMainFile(path string, config *denoConfig) string {
+	mainFiles := []string{"mod.ts", "src/mod.ts", "main.ts", "src/main.ts", "index.ts", "src/index.ts"}
+	for _, dir := range append([]string{""}, config.members()...) {
+		for _, mainFile := range mainFiles {
+			if dir != "" {
+				mainFile = dir + "/" + mainFile
+			}
+
+			if _, err := os.Stat(filepath.Join(path, mainFile)); err == nil {
+				return mainFile
+			}
+		}
+	}
+
+	return ""
+}
+
+// Reports whether the project uses the Fresh framework
+func isFreshProject(path string, config *denoConfig) bool {
+	if _, err := os.Stat(filepath.Join(path, "fresh.gen.ts")); err == nil {
+		return true
+	}
+
+	if _, ok := config.Imports["$fresh/"]; ok {
+		return true
+	}
+
+	return isFresh2Project(config)
+}
+
+// Fresh 2 builds a server entrypoint into _fresh/server.js
+func isFresh2Project(config *denoConfig) bool {
+	for _, specifier := range config.Imports {
+		if strings.HasPrefix(specifier, "jsr:@fresh/core") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Returns the command of a task, which is a string or an object with a "command"
+func denoTaskCommand(task any) string {
+	switch t := task.(type) {
+	case string:
+		return t
+	case map[string]any:
+		command, _ := t["command"].(string)
+		return command
+	}
+
+	return ""
+}
+
+// Reports whether a detected Deno version satisfies a constraint. Versions that
+// can't be parsed, like "latest", are assumed to be the newest release.
+func isDenoVersion(version string, constraint string) bool {
+	v, err := semver.NewVersion(version)
+	if err != nil {
+		return true
+	}
+
+	c, err := semver.NewConstraint(constraint)
+	if err != nil {
+		return false
+	}
+
+	return c.Check(v)
+}
+
+// Removes comments and trailing commas from JSONC so it can be decoded as JSON
+func stripJSONC(b []byte) []byte {
+	var out bytes.Buffer
+	inString := false
+	for i := 0; i < len(b); i++ {
+		c := b[i]
+		if inString {
+			out.WriteByte(c)
+			if c == '\\' && i+1 < len(b) {
+				i++
+				out.WriteByte(b[i])
+			} else if c == '"' {
+				inString = false
+			}
+			continue
+		}
+
+		switch {
+		case c == '"':
+			inString = true
+			out.WriteByte(c)
+		case c == '/' && i+1 < len(b) && b[i+1] == '/':
+			for i < len(b) && b[i] != '\n' {
+				i++
+			}
+			if i < len(b) {
+				out.WriteByte('\n')
+			}
+		case c == '/' && i+1 < len(b) && b[i+1] == '*':
+			i += 2
+			for i+1 < len(b) && !(b[i] == '*' && b[i+1] == '/') {
+				i++
+			}
+			i++
+		case c == '}' || c == ']':
+			// Drop a trailing comma before the closing bracket
+			trimmed := bytes.TrimRight(out.Bytes(), " \t\r\n")
+			if len(trimmed) > 0 && trimmed[len(trimmed)-1] == ',' {
+				rest := bytes.Clone(out.Bytes()[len(trimmed):])
+				out.Truncate(len(trimmed) - 1)
+				out.Write(rest)
+			}
+			out.WriteByte(c)
+		default:
+			out.WriteByte(c)
+		}
+	}
+
+	return out.Bytes()
+}
+
 func findDenoVersion(path string, log *slog.Logger) (*string, error) {
 	version := ""
 	versionFiles := []string{
@@ -1,6 +1,11 @@
 package runtime_test
 
 import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
 	"regexp"
 	"strings"
 	"testing"
@@ -24,6 +29,16 @@ func TestDenoMatch(t *testing.T) {
 			path:     "../testdata/deno-jsonc",
 			expected: true,
 		},
+		{
+			name:     "Deno project with jsr: imports",
+			path:     "../testdata/deno-imports",
+			expected: true,
+		},
+		{
+			name:     "Node project with Deno imports in node_modules and ignored files",
+			path:     "../testdata/node-deno-scripts",
+			expected: false,
+		},
 		{
 			name:     "Not a Deno project",
 			path:     "../testdata/ruby",
@@ -41,6 +56,71 @@ func TestDenoMatch(t *testing.T) {
 	}
 }
 
+func TestDenoMatchImportScan(t *testing.T) {
+	// More TypeScript files without Deno imports than the scan reads, sorted
+	// before the one file that has them
+	writeTree := func(t *testing.T, files int) string {
+		dir := t.TempDir()
+		if err := os.MkdirAll(filepath.Join(dir, "a"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < files; i++ {
+			if err := os.WriteFile(filepath.Join(dir, "a", fmt.Sprintf("file%04d.ts", i)), []byte("export {};\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := os.MkdirAll(filepath.Join(dir, "b"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "b", "main.ts"), []byte(`import { serve } from "jsr:@std/http";`+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return dir
+	}
+
+	tests := []struct {
+		name     string
+		path     func(t *testing.T) string
+		expected string
+	}{
+		{
+			name:     "Imports only in a directory ignored by .dockerignore",
+			path:     func(t *testing.T) string { return "../testdata/deno-dockerignore" },
+			expected: "",
+		},
+		{
+			name:     "Imports beyond the depth limit",
+			path:     func(t *testing.T) string { return "../testdata/deno-deep" },
+			expected: "z/main.ts",
+		},
+		{
+			name:     "Imports within the file limit",
+			path:     func(t *testing.T) string { return writeTree(t, 999) },
+			expected: "b/main.ts",
+		},
+		{
+			name:     "Imports beyond the file limit",
+			path:     func(t *testing.T) string { return writeTree(t, 1000) },
+			expected: "",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			deno := &runtime.Deno{Log: slog.New(slog.NewTextHandler(&buf, nil))}
+			matched := deno.Match(test.path(t))
+			if matched != (test.expected != "") {
+				t.Fatalf("expected %v, got %v", test.expected != "", matched)
+			}
+
+			if test.expected != "" && !strings.Contains(buf.String(), "Detected Deno project via imports in "+test.expected) {
+				t.Errorf("expected imports to be detected in %s, got log:\n%s", test.expected, buf.String())
+			}
+		})
+	}
+}
+
 func TestDenoGenerateDockerfile(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -51,12 +131,28 @@ func TestDenoGenerateDockerfile(t *testing.T) {
 		{
 			name:     "Deno project",
 			path:     "../testdata/deno",
-			expected: []any{`ARG VERSION=latest`, `ARG INSTALL_CMD="deno cache main.ts"`, `ARG START_CMD="deno run --allow-all main.ts"`},
+			expected: []any{`ARG VERSION=latest`, `ARG INSTALL_CMD="deno install --entrypoint main.ts"`, `ARG START_CMD="deno run --allow-net --allow-env --allow-read main.ts"`},
 		},
 		{
 			name:     "Deno project w/ mise",
 			path:     "../testdata/deno-mise",
-			expected: []any{`ARG VERSION=1.43.2`, `ARG INSTALL_CMD="deno cache main.ts"`, `ARG START_CMD="deno run --allow-all main.ts"`},
+			expected: []any{`ARG VERSION=1.43.2`, `ARG INSTALL_CMD="deno cache main.ts"`, `ARG START_CMD="deno run --allow-net --allow-env --allow-read main.ts"`},
+		},
+		{
+			name: "Deno project with imports, nodeModulesDir, unstable and deno.lock",
+			path: "../testdata/deno-config",
+			expected: []any{
+				`ARG INSTALL_CMD="deno install --frozen --entrypoint main.ts"`,
+				`ARG START_CMD="deno run --allow-net --allow-env --allow-read --allow-sys --allow-write main.ts"`,
+			},
+		},
+		{
+			name: "Deno workspace",
+			path: "../testdata/deno-workspace",
+			expected: []any{
+				`ARG INSTALL_CMD="deno install --entrypoint api/main.ts"`,
+				`ARG START_CMD="deno run --allow-net --allow-env --allow-read api/main.ts"`,
+			},
 		},
 		{
 			name:     "Deno project with .ts file",
@@ -70,6 +166,37 @@ func TestDenoGenerateDockerfile(t *testing.T) {
     `},
 			expected: []any{regexp.MustCompile(`^RUN --mount=type=secret,id=_env,target=/app/.env \\$`)},
 		},
+		{
+			name: "Fresh project",
+			path: "../testdata/deno-fresh",
+			expected: []any{
+				`ARG MODE=run`,
+				`ARG BUILD_CMD="deno task build"`,
+				`ARG START_CMD="deno run --allow-net --allow-env --allow-read main.ts"`,
+			},
+		},
+		{
+			name: "Fresh project compiled to a binary",
+			path: "../testdata/deno-fresh",
+			data: map[string]string{"Mode": "compile"},
+			expected: []any{
+				`ARG MODE=compile`,
+				`ARG COMPILE_FLAGS="--allow-net --allow-env --allow-read --include _fresh --include static"`,
+				`ARG MAIN_FILE="main.ts"`,
+				`FROM runtime-${MODE} AS runtime`,
+			},
+		},
+		{
+			name: "Fresh 2 project compiled to a binary",
+			path: "../testdata/deno-fresh2",
+			data: map[string]string{"Mode": "compile"},
+			expected: []any{
+				`ARG BUILD_CMD="deno task build"`,
+				`ARG START_CMD="deno task start"`,
+				`ARG COMPILE_FLAGS="--allow-net --allow-env --allow-read --allow-sys --include _fresh"`,
+				`ARG MAIN_FILE="_fresh/server.js"`,
+			},
+		},
 		{
 			name:     "Not a Deno project",
 			path:     "../testdata/ruby",
@@ -8,9 +8,13 @@ import (
 	"maps"
 	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
 	"text/template"
 
+	"github.com/Masterminds/semver/v3"
 	"github.com/pelletier/go-toml/v2"
 )
 
@@ -55,26 +59,118 @@ func (d *Elixir) GenerateDockerfile(path string, data ...map[string]string) ([]b
 		return nil, err
 	}
 
+	elixirVersion, otpVersion = resolveElixirImage(*elixirVersion, *otpVersion, d.Log)
+
 	binName, err := findBinName(path)
 	if err != nil {
 		return nil, err
 	}
 
+	appsPath, apps := findUmbrellaApps(path)
+	if appsPath != "" {
+		d.Log.Info(fmt.Sprintf("Detected umbrella project with apps: %s", strings.Join(apps, ", ")))
+	}
+
+	release := ""
+	releases := findElixirReleases(path)
+	if len(releases) > 0 {
+		release = releases[0]
+		if len(releases) > 1 {
+			d.Log.Info(fmt.Sprintf("Detected releases: %s. Using %s, set the RELEASE and BIN_NAME build args to build another", strings.Join(releases, ", "), release))
+		}
+	} else if appsPath != "" {
+		d.Log.Warn("Umbrella projects must declare a release in the releases keyword list of mix.exs")
+	}
+	if len(data) > 0 && data[0]["Release"] != "" {
+		release = data[0]["Release"]
+	}
+	if release != "" {
+		binName = release
+	}
+
+	isPhoenix := isPhoenixProject(path)
+	for _, app := range apps {
+		isPhoenix = isPhoenix || isPhoenixProject(filepath.Join(path, appsPath, app))
+	}
+	if isPhoenix {
+		d.Log.Info("Detected Phoenix project")
+	}
+
+	// Each app's mix.exs is needed to fetch the umbrella's dependencies
+	dependencyInstructions := []string{}
+	for _, app := range apps {
+		dir := filepath.ToSlash(filepath.Join(appsPath, app))
+		dependencyInstructions = append(dependencyInstructions, fmt.Sprintf("COPY %s/mix.exs %s/", dir, dir))
+	}
+
+	// Only copy what the project contains, so plain Mix projects and API-only
+	// Phoenix apps without assets/ build too
+	configInstructions := []string{}
+	if hasElixirFile(path, "config/config.exs") {
+		configFiles := "config/config.exs"
+		if hasElixirFile(path, "config/prod.exs") {
+			configFiles += " config/${MIX_ENV}.exs"
+		}
+		configInstructions = append(configInstructions, "COPY "+configFiles+" config/")
+	}
+
+	sourceInstructions := []string{}
+	for _, dir := range []string{"priv", "lib", "assets", appsPath} {
+		if dir != "" && hasElixirFile(path, dir) {
+			sourceInstructions = append(sourceInstructions, fmt.Sprintf("COPY %s %s", dir, dir))
+		}
+	}
+
+	assetsDeploy := hasAssetsDeployAlias(path)
+	if assetsDeploy {
+		d.Log.Info("Detected assets.deploy alias in mix.exs")
+		sourceInstructions = append(sourceInstructions, "RUN mix assets.deploy")
+	}
+	// Aliases of umbrella apps are only available from their own directory
+	for _, app := range apps {
+		if hasAssetsDeployAlias(filepath.Join(path, appsPath, app)) {
+			d.Log.Info("Detected assets.deploy alias in " + app)
+			assetsDeploy = true
+			sourceInstructions = append(sourceInstructions, fmt.Sprintf("RUN cd %s && mix assets.deploy", filepath.ToSlash(filepath.Join(appsPath, app))))
+		}
+	}
+
+	releaseInstructions := []string{}
+	if hasElixirFile(path, "config/runtime.exs") {
+		releaseInstructions = append(releaseInstructions, "COPY config/runtime.exs config/")
+	}
+	if hasElixirFile(path, "rel") {
+		releaseInstructions = append(releaseInstructions, "COPY rel rel")
+	}
+
+	phoenixInstructions := ""
+	if isPhoenix {
+		phoenixInstructions = phoenixServerInstructions
+	}
+
 	d.Log.Info(
 		fmt.Sprintf(`Detected defaults 
   Elixir version : %s
   Erlang version : %s
+  Release        : %s
   Binary name    : %s
+  Assets deploy  : %t
 
   Docker build arguments can supersede these defaults if provided.
-  See https://flexstack.com/docs/languages-and-frameworks/autogenerate-dockerfile`, *elixirVersion, *otpVersion, binName),
+  See https://flexstack.com/docs/languages-and-frameworks/autogenerate-dockerfile`, *elixirVersion, *otpVersion, release, binName, assetsDeploy),
 	)
 
 	var buf bytes.Buffer
 	templateData := map[string]string{
-		"ElixirVersion": *elixirVersion,
-		"OTPVersion":    strings.Split(*otpVersion, ".")[0],
-		"BinName":       binName,
+		"ElixirVersion":          *elixirVersion,
+		"OTPVersion":             strings.Split(*otpVersion, ".")[0],
+		"BinName":                binName,
+		"Release":                release,
+		"DependencyInstructions": elixirInstructions(dependencyInstructions),
+		"ConfigInstructions":     elixirInstructions(configInstructions),
+		"SourceInstructions":     elixirInstructions(sourceInstructions),
+		"ReleaseInstructions":    elixirInstructions(releaseInstructions),
+		"PhoenixInstructions":    phoenixInstructions,
 	}
 	if len(data) > 0 {
 		maps.Copy(templateData, data[0])
@@ -98,21 +194,15 @@ RUN apt-get update -y && apt-get install -y build-essential git \
 ENV MIX_ENV=prod
 RUN mix local.hex --force && mix local.rebar --force
 
-COPY mix.exs mix.lock ./
-RUN mix deps.get --only $MIX_ENV
+COPY mix.exs mix.lock* ./
+{{.DependencyInstructions}}RUN mix deps.get --only $MIX_ENV
 RUN mkdir config
+{{.ConfigInstructions}}RUN mix deps.compile
 
-COPY config/config.exs config/${MIX_ENV}.exs config/
-RUN mix deps.compile
-
-COPY priv priv
-COPY lib lib
-COPY assets assets
-RUN mix assets.deploy 
-RUN mix compile
+{{.SourceInstructions}}RUN mix compile
 
-COPY config/runtime.exs config/
-RUN mix release
+{{.ReleaseInstructions}}ARG RELEASE={{.Release}}
+RUN mix release ${RELEASE}
 
 FROM debian:stable-slim AS runtime
 WORKDIR /app
@@ -127,7 +217,7 @@ ENV LANGUAGE=en_US:en
 ENV LC_ALL=en_US.UTF-8
 
 ENV MIX_ENV="prod"
-
+{{.PhoenixInstructions}}
 # Only copy the final release from the build stage
 ARG BIN_NAME={{.BinName}}
 ENV BIN_NAME=${BIN_NAME}
@@ -147,6 +237,8 @@ func findElixirVersion(path string, log *slog.Logger) (*string, error) {
 	versionFiles := []string{
 		".tool-versions",
 		".elixir-version",
+		".mise.toml",
+		"mix.exs",
 	}
 
 	for _, file := range versionFiles {
@@ -190,6 +282,37 @@ func findElixirVersion(path string, log *slog.Logger) (*string, error) {
 				if err := scanner.Err(); err != nil {
 					return nil, fmt.Errorf("Failed to read .elixir-version file")
 				}
+
+			case ".mise.toml":
+				var mise MiseToml
+				if err := toml.NewDecoder(f).Decode(&mise); err != nil {
+					return nil, fmt.Errorf("Failed to decode .mise.toml file")
+				}
+				elixirVersion, ok := mise.Tools["elixir"].(string)
+				if !ok {
+					versions, ok := mise.Tools["elixir"].([]string)
+					if ok {
+						elixirVersion = versions[0]
+					}
+				}
+				if elixirVersion != "" {
+					version = elixirVersion
+					log.Info("Detected Elixir version in .mise.toml: " + version)
+				}
+
+			case "mix.exs":
+				scanner := bufio.NewScanner(f)
+				for scanner.Scan() {
+					if match := elixirRequirementRe.FindStringSubmatch(scanner.Text()); match != nil {
+						version = resolveElixirRequirement(match[1])
+						log.Info("Detected Elixir version from mix.exs: " + version)
+						break
+					}
+				}
+
+				if err := scanner.Err(); err != nil {
+					return nil, fmt.Errorf("Failed to read mix.exs file")
+				}
 			}
 
 			f.Close()
@@ -200,7 +323,7 @@ func findElixirVersion(path string, log *slog.Logger) (*string, error) {
 	}
 
 	if version == "" {
-		version = "1.12"
+		version = "1.18"
 		log.Info(fmt.Sprintf("No Elixir version detected. Using: %s", version))
 	}
 
@@ -283,16 +406,263 @@ func findOTPVersion(path string, log *slog.Logger) (*string, error) {
 		}
 	}
 
-	if version == "" {
-		version = "26.2.5"
-		log.Info(fmt.Sprintf("No Erlang version detected. Using: %s", version))
+	// Without a version, the newest release supported by Elixir is used
+	return &version, nil
+}
+
+type elixirRelease struct {
+	Minor string
+	// The latest patch release, which has an image for each supported OTP
+	Patch string
+	// Supported OTP major versions, oldest first
+	OTP []int
+}
+
+// Elixir minor releases and the OTP versions they support, newest first. See
+// https://hexdocs.pm/elixir/compatibility-and-deprecations.html
+var elixirReleases = []elixirRelease{
+	{"1.19", "1.19.0", []int{26, 27, 28}},
+	{"1.18", "1.18.4", []int{25, 26, 27, 28}},
+	{"1.17", "1.17.3", []int{25, 26, 27}},
+	{"1.16", "1.16.3", []int{24, 25, 26}},
+	{"1.15", "1.15.8", []int{24, 25, 26}},
+	{"1.14", "1.14.5", []int{23, 24, 25, 26}},
+	{"1.13", "1.13.4", []int{22, 23, 24, 25}},
+	{"1.12", "1.12.3", []int{22, 23, 24}},
+	{"1.11", "1.11.4", []int{21, 22, 23, 24}},
+	{"1.10", "1.10.4", []int{21, 22, 23}},
+}
+
+// Resolves the Elixir and OTP versions to a pairing with a published
+// ${VERSION}-otp-${OTP_VERSION} image. Elixir versions like 1.15.7-otp-26
+// from asdf carry their own OTP version.
+func resolveElixirImage(elixir string, otp string, log *slog.Logger) (*string, *string) {
+	if v, otpMajor, ok := strings.Cut(elixir, "-otp-"); ok {
+		elixir = v
+		if otp == "" {
+			otp = otpMajor
+		}
 	}
 
-	return &version, nil
+	parts := strings.Split(elixir, ".")
+	minor := strings.Join(parts[:min(len(parts), 2)], ".")
+	idx := slices.IndexFunc(elixirReleases, func(r elixirRelease) bool {
+		return r.Minor == minor
+	})
+	if idx == -1 {
+		if otp == "" {
+			// Fall back to the newest OTP version of any known release, since
+			// Elixir releases newer than the table support it too
+			newest := 0
+			for _, release := range elixirReleases {
+				newest = max(newest, release.OTP[len(release.OTP)-1])
+			}
+			otp = strconv.Itoa(newest)
+		}
+		log.Warn(fmt.Sprintf("Elixir %s is not a known release, so its pairing with OTP %s can't be checked", elixir, otp))
+		return &elixir, &otp
+	}
+
+	release := elixirReleases[idx]
+	if len(parts) < 3 {
+		elixir = release.Patch
+	}
+
+	supported := release.OTP
+	newest := supported[len(supported)-1]
+	if otp == "" {
+		otp = strconv.Itoa(newest)
+		log.Info(fmt.Sprintf("No Erlang version detected. Using: %s", otp))
+	} else if major, _ := strconv.Atoi(strings.Split(otp, ".")[0]); !slices.Contains(supported, major) {
+		// Use the nearest supported OTP at or above the detected one, so that
+		// pinned major versions aren't skipped
+		replacement := newest
+		for _, v := range supported {
+			if v >= major {
+				replacement = v
+				break
+			}
+		}
+		log.Warn(fmt.Sprintf("Elixir %s does not support OTP %s. Using OTP %d instead", elixir, otp, replacement))
+		otp = strconv.Itoa(replacement)
+	}
+
+	return &elixir, &otp
 }
 
+// Returns the newest Elixir minor release allowed by a mix.exs version
+// requirement, e.g. "~> 1.15".
+func resolveElixirRequirement(requirement string) string {
+	constraint := strings.NewReplacer(" and ", ", ", " or ", " || ", "==", "=").Replace(requirement)
+	constraint = elixirPessimisticRe.ReplaceAllStringFunc(constraint, func(r string) string {
+		// ~> 1.15 allows any 1.x release from 1.15, while ~> 1.15.4 allows 1.15.x releases
+		version := strings.TrimSpace(strings.TrimPrefix(r, "~>"))
+		if strings.Count(version, ".") == 1 {
+			major, _ := strconv.Atoi(strings.Split(version, ".")[0])
+			return fmt.Sprintf(">=%s, <%d", version, major+1)
+		}
+		return "~" + version
+	})
+
+	constraints, err := semver.NewConstraint(constraint)
+	if err != nil {
+		return strings.TrimSuffix(exactVersionRe.FindString(strings.TrimLeft(requirement, "=<>~ ")), ".")
+	}
+
+	for _, release := range elixirReleases {
+		if constraints.Check(semver.MustParse(release.Patch)) {
+			return release.Minor
+		}
+	}
+
+	return strings.TrimSuffix(exactVersionRe.FindString(strings.TrimLeft(requirement, "=<>~ ")), ".")
+}
+
+var elixirRequirementRe = regexp.MustCompile(`^\s*elixir:\s*"([^"]+)"`)
+var elixirPessimisticRe = regexp.MustCompile(`~>\s*[\d.]+`)
+
+var phoenixServerInstructions = `
+# Start the Phoenix endpoint when the release boots
+ENV PHX_SERVER=true
+`
+
 func isPhoenixProject(path string) bool {
-	_, err := os.Stat(filepath.Join(path, "config/config.exs"))
+	contents, err := os.ReadFile(filepath.Join(path, "mix.exs"))
+	if err != nil {
+		return false
+	}
+
+	return phoenixDepRe.Match(contents)
+}
+
+var phoenixDepRe = regexp.MustCompile(`\{:phoenix,`)
+
+// Returns the apps_path of an umbrella project and the apps within it.
+func findUmbrellaApps(path string) (string, []string) {
+	contents, err := os.ReadFile(filepath.Join(path, "mix.exs"))
+	if err != nil {
+		return "", nil
+	}
+
+	match := appsPathRe.FindSubmatch(contents)
+	if match == nil {
+		return "", nil
+	}
+
+	appsPath := string(match[1])
+	apps := []string{}
+	mixFiles, _ := filepath.Glob(filepath.Join(path, appsPath, "*", "mix.exs"))
+	for _, fp := range mixFiles {
+		apps = append(apps, filepath.Base(filepath.Dir(fp)))
+	}
+
+	return appsPath, apps
+}
+
+var appsPathRe = regexp.MustCompile(`apps_path:\s*"([^"]+)"`)
+
+// Returns the names of the releases in the releases keyword list of mix.exs,
+// which may be inline or returned by a function like releases/0.
+func findElixirReleases(path string) []string {
+	contents, err := os.ReadFile(filepath.Join(path, "mix.exs"))
+	if err != nil {
+		return nil
+	}
+
+	mixExs := string(contents)
+	loc := releasesKeywordRe.FindStringSubmatchIndex(mixExs)
+	if loc == nil {
+		return nil
+	}
+
+	list := mixExs[loc[1]:]
+	if loc[2] != -1 {
+		fn := regexp.MustCompile(`defp?\s+` + regexp.QuoteMeta(mixExs[loc[2]:loc[3]]) + `(\(\))?\s+do\s*`)
+		fnLoc := fn.FindStringIndex(mixExs)
+		if fnLoc == nil {
+			return nil
+		}
+		list = mixExs[fnLoc[1]:]
+	}
+
+	if !strings.HasPrefix(list, "[") {
+		return nil
+	}
+
+	releases := []string{}
+	for _, entry := range splitElixirList(list) {
+		if match := keywordKeyRe.FindStringSubmatch(entry); match != nil {
+			releases = append(releases, match[1])
+		}
+	}
+
+	return releases
+}
+
+var releasesKeywordRe = regexp.MustCompile(`\breleases:\s*(?:(\w+)(?:\(\))?)?`)
+var keywordKeyRe = regexp.MustCompile(`^(\w+):`)
+
+// Splits the top level entries of the Elixir list at the start of s.
+func splitElixirList(s string) []string {
+	entries := []string{}
+	depth := 0
+	inString := false
+	start := 1
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if inString {
+			if c == '\\' {
+				i++
+			} else if c == '"' {
+				inString = false
+			}
+			continue
+		}
+
+		switch c {
+		case '"':
+			inString = true
+		case '[', '(', '{':
+			depth++
+		case ']', ')', '}':
+			depth--
+			if depth == 0 {
+				return append(entries, strings.TrimSpace(s[start:i]))
+			}
+		case ',':
+			if depth == 1 {
+				entries = append(entries, strings.TrimSpace(s[start:i]))
+				start = i + 1
+			}
+		}
+	}
+
+	return entries
+}
+
+func hasAssetsDeployAlias(path string) bool {
+	contents, err := os.ReadFile(filepath.Join(path, "mix.exs"))
+	if err != nil {
+		return false
+	}
+
+	return assetsDeployAliasRe.Match(contents)
+}
+
+var assetsDeployAliasRe = regexp.MustCompile(`"assets\.deploy"\s*:`)
+
+// Joins instructions into lines that sit above the next instruction in the
+// template.
+func elixirInstructions(instructions []string) string {
+	if len(instructions) == 0 {
+		return ""
+	}
+
+	return strings.Join(instructions, "\n") + "\n"
+}
+
+func hasElixirFile(path string, name string) bool {
+	_, err := os.Stat(filepath.Join(path, name))
 	return err == nil
 }
 
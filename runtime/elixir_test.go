@@ -45,34 +45,98 @@ func TestElixirGenerateDockerfile(t *testing.T) {
 	tests := []struct {
 		name     string
 		path     string
+		data     map[string]string
 		expected []any
 	}{
 		{
 			name:     "Elixir project",
 			path:     "../testdata/elixir",
-			expected: []any{`ARG VERSION=1.10`, `ARG OTP_VERSION=22`, `ARG BIN_NAME=hello`},
+			expected: []any{`ARG VERSION=1.10.4`, `ARG OTP_VERSION=22`, `ARG BIN_NAME=hello`},
 		},
 		{
 			name:     "Elixir project w/ mise",
 			path:     "../testdata/elixir-mise",
-			expected: []any{`ARG VERSION=1.10`, `ARG OTP_VERSION=23`, `ARG BIN_NAME=hello`},
+			expected: []any{`ARG VERSION=1.10.4`, `ARG OTP_VERSION=23`, `ARG BIN_NAME=hello`},
 		},
 		{
 			name:     "Elixir project with .tool-versions",
 			path:     "../testdata/elixir-tool-versions",
-			expected: []any{`ARG VERSION=1.11`, `ARG OTP_VERSION=23`, `ARG BIN_NAME=hello`},
+			expected: []any{`ARG VERSION=1.11.4`, `ARG OTP_VERSION=23`, `ARG BIN_NAME=hello`},
+		},
+		{
+			name:     "Elixir project w/ unsupported OTP version",
+			path:     "../testdata/elixir-otp-mismatch",
+			expected: []any{`ARG VERSION=1.17.3`, `ARG OTP_VERSION=25`},
+		},
+		{
+			name:     "Elixir project newer than the known releases",
+			path:     "../testdata/elixir-unknown-version",
+			expected: []any{`ARG VERSION=1.20.0`, `ARG OTP_VERSION=28`},
+		},
+		{
+			name: "Elixir project with Phoenix",
+			path: "../testdata/elixir-phoenix",
+			expected: []any{
+				`COPY config/config.exs config/${MIX_ENV}.exs config/`,
+				`COPY priv priv`,
+				`COPY assets assets`,
+				`RUN mix assets.deploy`,
+				`COPY config/runtime.exs config/`,
+				`ENV PHX_SERVER=true`,
+			},
+		},
+		{
+			name: "Elixir project with API-only Phoenix",
+			path: "../testdata/elixir-phoenix-api",
+			expected: []any{
+				regexp.MustCompile(`^COPY config/config.exs config/$`),
+				`COPY lib lib`,
+				regexp.MustCompile(`^RUN mix compile$`),
+				`ENV PHX_SERVER=true`,
+			},
+		},
+		{
+			name: "Elixir project without Phoenix",
+			path: "../testdata/elixir-mix",
+			expected: []any{
+				`ARG VERSION=1.19.0`,
+				`ARG OTP_VERSION=28`,
+				regexp.MustCompile(`^RUN mkdir config$`),
+				`COPY lib lib`,
+				`ARG BIN_NAME=worker`,
+			},
+		},
+		{
+			name: "Elixir umbrella project",
+			path: "../testdata/elixir-umbrella",
+			expected: []any{
+				`COPY apps/shop/mix.exs apps/shop/`,
+				`COPY apps/shop_web/mix.exs apps/shop_web/`,
+				`COPY apps apps`,
+				`RUN cd apps/shop_web && mix assets.deploy`,
+				`ARG RELEASE=store`,
+				`RUN mix release ${RELEASE}`,
+				`ARG BIN_NAME=store`,
+				`ENV PHX_SERVER=true`,
+			},
+		},
+		{
+			name:     "Elixir umbrella project with a release",
+			path:     "../testdata/elixir-umbrella",
+			data:     map[string]string{"Release": "admin"},
+			expected: []any{`ARG RELEASE=admin`, `ARG BIN_NAME=admin`},
 		},
 		{
 			name:     "Not a Elixir project",
 			path:     "../testdata/deno",
-			expected: []any{`ARG VERSION=1.12`, `ARG OTP_VERSION=26`, regexp.MustCompile(`^ARG BIN_NAME=$`)},
+			expected: []any{`ARG VERSION=1.18.4`, `ARG OTP_VERSION=28`, regexp.MustCompile(`^ARG BIN_NAME=$`)},
 		},
 	}
 
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
 			elixir := &runtime.Elixir{Log: logger}
-			dockerfile, err := elixir.GenerateDockerfile(test.path)
+			dockerfile, err := elixir.GenerateDockerfile(test.path, test.data)
 			if err != nil {
 				t.Errorf("unexpected error: %v", err)
 			}
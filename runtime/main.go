@@ -1,5 +1,7 @@
 package runtime
 
+import "slices"
+
 // An interface that all runtimes must implement.
 type Runtime interface {
 	// Returns the name of the runtime.
@@ -26,3 +28,40 @@ const (
 	RuntimeNameNode   RuntimeName = "Node"
 	RuntimeNameStatic RuntimeName = "Static"
 )
+
+// Debian packages a language dependency needs to build and run.
+type systemPackages struct {
+	// Debian packages needed to compile the dependency
+	Build []string
+	// Debian packages with the shared libraries the dependency loads at runtime
+	Runtime []string
+}
+
+// Returns the sorted Debian build-time and runtime packages needed by the
+// given dependencies according to packageMap.
+func findSystemPackages(packageMap map[string]systemPackages, dependencies map[string]bool) ([]string, []string) {
+	build := []string{}
+	runtime := []string{}
+
+	for name, pkgs := range packageMap {
+		if !dependencies[name] {
+			continue
+		}
+
+		for _, pkg := range pkgs.Build {
+			if !slices.Contains(build, pkg) {
+				build = append(build, pkg)
+			}
+		}
+
+		for _, pkg := range pkgs.Runtime {
+			if !slices.Contains(runtime, pkg) {
+				runtime = append(runtime, pkg)
+			}
+		}
+	}
+
+	slices.Sort(build)
+	slices.Sort(runtime)
+	return build, runtime
+}
@@ -10,8 +10,13 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"slices"
+	"strconv"
 	"strings"
 	"text/template"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/pelletier/go-toml/v2"
 )
 
 type PHP struct {
@@ -51,11 +56,31 @@ func (d *PHP) GenerateDockerfile(path string, data ...map[string]string) ([]byte
 		return nil, err
 	}
 
-	startCMD := "apache2-foreground"
+	server := "apache"
+	if composer, err := readComposerJSON(path); err == nil && composer.Require["runtime/frankenphp-symfony"] != "" {
+		server = "frankenphp"
+	}
+	if len(data) > 0 && data[0]["Server"] != "" {
+		server = data[0]["Server"]
+	}
+	serverCMD, ok := phpServerCommands[server]
+	if !ok {
+		return nil, fmt.Errorf("Failed to select PHP server: %s is not one of apache, nginx or frankenphp", server)
+	}
+	d.Log.Info("Using PHP server: " + server)
+
+	// The start command defaults to the server's own command in the template
+	startCMD := ""
 	installCMD := ""
+	composerInstructions := ""
 	if _, err := os.Stat(filepath.Join(path, "composer.json")); err == nil {
 		d.Log.Info("Detected composer.json file")
-		installCMD = "composer update && composer install --prefer-dist --no-dev --optimize-autoloader --no-interaction"
+		if _, err := os.Stat(filepath.Join(path, "composer.lock")); err != nil {
+			d.Log.Warn("No composer.lock file found. Commit one for reproducible builds, or set the COMPOSER_UPDATE=1 build arg to resolve dependencies during the build")
+		}
+		// PHP and its extensions come from the runtime image, not the Composer image
+		installCMD = "composer install --prefer-dist --no-dev --optimize-autoloader --no-interaction --ignore-platform-req=php --ignore-platform-req=ext-*"
+		composerInstructions = composerDependencyInstructions
 	}
 
 	packageManager := ""
@@ -117,23 +142,73 @@ func (d *PHP) GenerateDockerfile(path string, data ...map[string]string) ([]byte
 		f.Close()
 	}
 
+	documentRoot := ""
+	optimizeCMD := ""
+	releaseCMD := ""
+	workerCMD := ""
+	schedulerCMD := ""
+	frameworkInstructions := ""
+	requiredExtensions := []string{}
+	wordPressRoot, isWordPress := findWordPressRoot(path)
+	if isLaravelProject(path) {
+		d.Log.Info("Detected Laravel project")
+		documentRoot = "public"
+		// Caches are built when the container starts so they see the runtime environment
+		optimizeCMD = "php artisan config:cache && php artisan route:cache && php artisan view:cache"
+		releaseCMD = "php artisan migrate --force"
+		workerCMD = "php artisan queue:work --tries=3"
+		schedulerCMD = "php artisan schedule:work"
+		frameworkInstructions = laravelInstructions
+	} else if isSymfonyProject(path) {
+		d.Log.Info("Detected Symfony project")
+		documentRoot = "public"
+	} else if isWordPress {
+		d.Log.Info("Detected WordPress project")
+		documentRoot = wordPressRoot
+		requiredExtensions = wordPressExtensions
+	}
+
+	extensions, peclExtensions, extensionPackages, unknownExtensions := findPHPExtensions(path, requiredExtensions...)
+	if len(unknownExtensions) > 0 {
+		d.Log.Warn("Unable to install PHP extensions: " + strings.Join(unknownExtensions, ", "))
+	}
+
 	d.Log.Info(
 		fmt.Sprintf(`Detected defaults 
-  PHP version     : %s
-  Install command : %s
-  Build command   : %s
-  Start command   : %s
+  PHP version       : %s
+  Server            : %s
+  PHP extensions    : %s
+  PECL extensions   : %s
+  Document root     : %s
+  Install command   : %s
+  Build command     : %s
+  Optimize command  : %s
+  Start command     : %s
+  Release command   : %s
+  Worker command    : %s
+  Scheduler command : %s
 
   Docker build arguments can supersede these defaults if provided.
-  See https://flexstack.com/docs/languages-and-frameworks/autogenerate-dockerfile`, *version, installCMD, buildCMD, startCMD),
+  See https://flexstack.com/docs/languages-and-frameworks/autogenerate-dockerfile`, *version, server, strings.Join(extensions, " "), strings.Join(peclExtensions, " "), "/"+documentRoot, installCMD, buildCMD, optimizeCMD, serverCMD, releaseCMD, workerCMD, schedulerCMD),
 	)
 
 	var buf bytes.Buffer
 	templateData := map[string]string{
-		"Version":    *version,
-		"InstallCMD": safeCommand(installCMD),
-		"BuildCMD":   safeCommand(buildCMD),
-		"StartCMD":   safeCommand(startCMD),
+		"Version":               *version,
+		"Server":                server,
+		"ComposerInstructions":  composerInstructions,
+		"InstallCMD":            safeCommand(installCMD),
+		"BuildCMD":              safeCommand(buildCMD),
+		"StartCMD":              safeCommand(startCMD),
+		"DocumentRoot":          safeCommand(documentRoot),
+		"OptimizeCMD":           safeCommand(optimizeCMD),
+		"ReleaseCMD":            safeCommand(releaseCMD),
+		"WorkerCMD":             safeCommand(workerCMD),
+		"SchedulerCMD":          safeCommand(schedulerCMD),
+		"FrameworkInstructions": frameworkInstructions,
+		"PHPExtensions":         safeCommand(strings.Join(extensions, " ")),
+		"PECLExtensions":        safeCommand(strings.Join(peclExtensions, " ")),
+		"ExtensionPackages":     safeCommand(strings.Join(extensionPackages, " ")),
 	}
 	if len(data) > 0 {
 		maps.Copy(templateData, data[0])
@@ -148,9 +223,12 @@ func (d *PHP) GenerateDockerfile(path string, data ...map[string]string) ([]byte
 var phpTemplate = strings.TrimSpace(`
 ARG VERSION={{.Version}}
 ARG BUILDER=docker.io/library/composer
+# The web server: apache, nginx (with php-fpm) or frankenphp
+ARG SERVER={{.Server}}
 FROM ${BUILDER}:lts as build
 RUN apk add --no-cache nodejs npm
 WORKDIR /app
+{{.ComposerInstructions}}
 COPY . .
 
 ARG INSTALL_CMD={{.InstallCMD}}
@@ -158,29 +236,384 @@ ARG BUILD_CMD={{.BuildCMD}}
 RUN {{.InstallMounts}}if [ ! -z "${INSTALL_CMD}" ]; then sh -c "$INSTALL_CMD"; fi
 RUN {{.BuildMounts}}if [ ! -z "${BUILD_CMD}" ]; then sh -c "$BUILD_CMD"; fi
 
-FROM php:${VERSION}-apache AS runtime
-
+# Apache reads PORT and SERVER_ROOT from the environment when it starts
+FROM php:${VERSION}-apache AS runtime-apache
+RUN sed -i 's/^Listen 80$/Listen ${PORT}/' /etc/apache2/ports.conf \
+    && sed -i -e 's/<VirtualHost \*:80>/<VirtualHost *:${PORT}>/' -e 's!/var/www/html!${SERVER_ROOT}!g' /etc/apache2/sites-available/000-default.conf \
+    && echo 'FallbackResource /index.php' > /etc/apache2/conf-available/front-controller.conf \
+    && a2enconf front-controller && a2enmod rewrite
+ENV SERVER_CMD=apache2-foreground
+
+# nginx proxies to php-fpm on port 9000. Its config is rendered from PORT and
+# SERVER_ROOT when the container starts, so it can run as any user.
+FROM php:${VERSION}-fpm AS runtime-nginx
+RUN apt-get update && apt-get install -y --no-install-recommends nginx && apt-get clean && rm -f /var/lib/apt/lists/*_*
+RUN { \
+      echo 'worker_processes auto;'; \
+      echo 'pid /tmp/nginx.pid;'; \
+      echo 'error_log /dev/stderr;'; \
+      echo 'events { worker_connections 1024; }'; \
+      echo 'http {'; \
+      echo '  include /etc/nginx/mime.types;'; \
+      echo '  access_log /dev/stdout;'; \
+      echo '  client_body_temp_path /tmp/nginx-client-body;'; \
+      echo '  fastcgi_temp_path /tmp/nginx-fastcgi;'; \
+      echo '  proxy_temp_path /tmp/nginx-proxy;'; \
+      echo '  scgi_temp_path /tmp/nginx-scgi;'; \
+      echo '  uwsgi_temp_path /tmp/nginx-uwsgi;'; \
+      echo '  server {'; \
+      echo '    listen __PORT__;'; \
+      echo '    root __SERVER_ROOT__;'; \
+      echo '    index index.php index.html;'; \
+      echo '    location / { try_files $uri $uri/ /index.php$is_args$args; }'; \
+      echo '    location ~ /\.(?!well-known) { deny all; }'; \
+      echo '    location ~ \.php$ {'; \
+      echo '      try_files $uri =404;'; \
+      echo '      include /etc/nginx/fastcgi_params;'; \
+      echo '      fastcgi_param SCRIPT_FILENAME $realpath_root$fastcgi_script_name;'; \
+      echo '      fastcgi_param DOCUMENT_ROOT $realpath_root;'; \
+      echo '      fastcgi_pass 127.0.0.1:9000;'; \
+      echo '    }'; \
+      echo '  }'; \
+      echo '}'; \
+    } > /etc/nginx/php-fpm.conf.template \
+    && { \
+      echo '#!/bin/sh'; \
+      echo 'set -e'; \
+      echo 'sed -e "s|__PORT__|${PORT}|g" -e "s|__SERVER_ROOT__|${SERVER_ROOT}|g" /etc/nginx/php-fpm.conf.template > /tmp/nginx.conf'; \
+      echo 'php-fpm --daemonize'; \
+      echo 'exec nginx -c /tmp/nginx.conf -e /dev/stderr -g "daemon off;"'; \
+    } > /usr/local/bin/nginx-php-fpm \
+    && chmod +x /usr/local/bin/nginx-php-fpm
+ENV SERVER_CMD=nginx-php-fpm
+
+# FrankenPHP serves PHP from Caddy, which reads PORT and SERVER_ROOT when it starts
+FROM docker.io/dunglas/frankenphp:php${VERSION} AS runtime-frankenphp
+RUN mkdir -p /etc/caddy && { \
+      echo '{'; \
+      echo '  frankenphp'; \
+      echo '}'; \
+      echo ':{$PORT} {'; \
+      echo '  root * {$SERVER_ROOT}'; \
+      echo '  encode zstd br gzip'; \
+      echo '  php_server'; \
+      echo '}'; \
+    } > /etc/caddy/Caddyfile.php
+ENV XDG_CONFIG_HOME=/tmp/caddy/config XDG_DATA_HOME=/tmp/caddy/data
+ENV SERVER_CMD="frankenphp run --config /etc/caddy/Caddyfile.php --adapter caddyfile"
+
+FROM runtime-${SERVER} AS runtime
+WORKDIR /var/www/html
 RUN apt-get update && apt-get install -y --no-install-recommends wget ca-certificates && apt-get clean && rm -f /var/lib/apt/lists/*_*
 RUN update-ca-certificates 2>/dev/null || true
-RUN addgroup --system nonroot && adduser --system --ingroup nonroot nonroot	
+RUN addgroup --system nonroot && adduser --system --ingroup nonroot nonroot
+
+# Extensions required by composer.json and composer.lock. Their -dev packages are
+# removed once compiled, keeping only the shared libraries the extensions link to.
+ARG PHP_EXTENSIONS={{.PHPExtensions}}
+ARG PECL_EXTENSIONS={{.PECLExtensions}}
+ARG EXTENSION_PACKAGES={{.ExtensionPackages}}
+RUN if [ ! -z "${PHP_EXTENSIONS}${PECL_EXTENSIONS}" ]; then \
+      savedAptMark="$(apt-mark showmanual)" \
+      && apt-get update && apt-get install -y --no-install-recommends ${EXTENSION_PACKAGES} \
+      && case " ${PHP_EXTENSIONS} " in *" gd "*) docker-php-ext-configure gd --with-freetype --with-jpeg --with-webp ;; esac \
+      && if [ ! -z "${PHP_EXTENSIONS}" ]; then docker-php-ext-install -j"$(nproc)" ${PHP_EXTENSIONS}; fi \
+      && if [ ! -z "${PECL_EXTENSIONS}" ]; then pecl install ${PECL_EXTENSIONS} && docker-php-ext-enable ${PECL_EXTENSIONS}; fi \
+      && apt-mark auto '.*' > /dev/null && apt-mark manual ${savedAptMark} > /dev/null \
+      && find /usr/local/lib/php/extensions -name '*.so' -exec ldd {} + \
+        | awk '/=>/ { so = $(NF-1); if (index(so, "/usr/local/") == 1) { next }; gsub("^/(usr/)?", "", so); printf "*%s\n", so }' \
+        | sort -u | xargs -r dpkg-query --search | cut -d: -f1 | sort -u | xargs -r apt-mark manual > /dev/null \
+      && apt-get purge -y --auto-remove -o APT::AutoRemove::RecommendsImportant=false \
+      && rm -rf /tmp/pear ~/.pearrc /var/lib/apt/lists/*; \
+    fi
 	
 ENV PORT=8080
 EXPOSE ${PORT}
-RUN sed -i "s/80/${PORT}/g" /etc/apache2/sites-available/000-default.conf /etc/apache2/ports.conf
-COPY --from=build --chown=nonroot:nonroot /app /var/www/html
 
+# Serve the project from a subdirectory, e.g. public/ for Laravel
+ARG DOCUMENT_ROOT={{.DocumentRoot}}
+ENV SERVER_ROOT=/var/www/html/${DOCUMENT_ROOT}
+COPY --from=build --chown=nonroot:nonroot /app /var/www/html
+{{.FrameworkInstructions}}
 USER nonroot:nonroot
 
+# An optional command to warm framework caches when the container starts
+ARG OPTIMIZE_CMD={{.OptimizeCMD}}
+ENV OPTIMIZE_CMD=${OPTIMIZE_CMD}
+# An optional command, e.g. database migrations, to run before a new release receives traffic
+ARG RELEASE_CMD={{.ReleaseCMD}}
+ENV RELEASE_CMD=${RELEASE_CMD}
+# Defaults to the command of the selected server
 ARG START_CMD={{.StartCMD}}
-ENV START_CMD=${START_CMD}
+ENV START_CMD=${START_CMD:-${SERVER_CMD}}
 RUN if [ -z "${START_CMD}" ]; then echo "Unable to detect a container start command" && exit 1; fi
-CMD ${START_CMD}
+
+# The same image can run background processes by setting PROCESS to "worker" or "scheduler"
+ARG WORKER_CMD={{.WorkerCMD}}
+ENV WORKER_CMD=${WORKER_CMD}
+ARG SCHEDULER_CMD={{.SchedulerCMD}}
+ENV SCHEDULER_CMD=${SCHEDULER_CMD}
+ARG PROCESS=web
+ENV PROCESS=${PROCESS}
+CMD if [ ! -z "${OPTIMIZE_CMD}" ]; then sh -c "$OPTIMIZE_CMD" || exit 1; fi; if [ "${PROCESS}" = "worker" ]; then exec ${WORKER_CMD:?WORKER_CMD is not set}; elif [ "${PROCESS}" = "scheduler" ]; then exec ${SCHEDULER_CMD:?SCHEDULER_CMD is not set}; else exec ${START_CMD}; fi
 `)
 
+var composerDependencyInstructions = `
+# Dependencies are installed from composer.lock in their own layer. Scripts run
+# with INSTALL_CMD once the project is copied, since they usually need its source.
+ENV COMPOSER_CACHE_DIR=/tmp/composer-cache
+COPY composer.json composer.lock* ./
+ARG COMPOSER_UPDATE=
+RUN if [ ! -f composer.lock ]; then \
+      if [ "${COMPOSER_UPDATE}" = "1" ]; then composer update --no-install --no-interaction --ignore-platform-req=php --ignore-platform-req=ext-*; \
+      else echo "composer.lock not found. Commit it, or set COMPOSER_UPDATE=1 to resolve dependencies during the build" && exit 1; fi; \
+    fi
+RUN --mount=type=cache,target=/tmp/composer-cache composer install --prefer-dist --no-dev --no-scripts --no-autoloader --no-interaction --ignore-platform-req=php --ignore-platform-req=ext-*
+`
+
+var phpServerCommands = map[string]string{
+	"apache":     "apache2-foreground",
+	"nginx":      "nginx-php-fpm",
+	"frankenphp": "frankenphp run --config /etc/caddy/Caddyfile.php --adapter caddyfile",
+}
+
+var laravelInstructions = `
+# Laravel writes caches, sessions and logs to storage/ and bootstrap/cache
+RUN mkdir -p storage/framework/cache storage/framework/sessions storage/framework/views storage/logs bootstrap/cache \
+    && chown -R nonroot:nonroot storage bootstrap/cache && chmod -R ug+rwX storage bootstrap/cache
+`
+
+type composerJSON struct {
+	Require    map[string]string `json:"require"`
+	RequireDev map[string]string `json:"require-dev"`
+	Config     struct {
+		Platform map[string]any `json:"platform"`
+	} `json:"config"`
+}
+
+func readComposerJSON(path string) (*composerJSON, error) {
+	f, err := os.Open(filepath.Join(path, "composer.json"))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var composer composerJSON
+	if err := json.NewDecoder(f).Decode(&composer); err != nil {
+		return nil, fmt.Errorf("Failed to decode composer.json file")
+	}
+
+	return &composer, nil
+}
+
+type composerLock struct {
+	Packages []struct {
+		Require map[string]string `json:"require"`
+	} `json:"packages"`
+	// Empty platform requirements are encoded as [] rather than {}
+	Platform          any `json:"platform"`
+	PlatformDev       any `json:"platform-dev"`
+	PlatformOverrides any `json:"platform-overrides"`
+}
+
+func readComposerLock(path string) (*composerLock, error) {
+	f, err := os.Open(filepath.Join(path, "composer.lock"))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var lock composerLock
+	if err := json.NewDecoder(f).Decode(&lock); err != nil {
+		return nil, fmt.Errorf("Failed to decode composer.lock file")
+	}
+
+	return &lock, nil
+}
+
+type phpExtension struct {
+	// Installed with pecl rather than docker-php-ext-install
+	PECL bool
+	// Debian packages needed to compile the extension
+	Packages []string
+}
+
+var phpExtensionMap = map[string]phpExtension{
+	"bcmath":    {},
+	"bz2":       {Packages: []string{"libbz2-dev"}},
+	"calendar":  {},
+	"exif":      {},
+	"gd":        {Packages: []string{"libfreetype-dev", "libjpeg62-turbo-dev", "libpng-dev", "libwebp-dev"}},
+	"gettext":   {},
+	"gmp":       {Packages: []string{"libgmp-dev"}},
+	"intl":      {Packages: []string{"libicu-dev"}},
+	"ldap":      {Packages: []string{"libldap2-dev"}},
+	"mysqli":    {},
+	"opcache":   {},
+	"pcntl":     {},
+	"pdo_mysql": {},
+	"pdo_pgsql": {Packages: []string{"libpq-dev"}},
+	"pgsql":     {Packages: []string{"libpq-dev"}},
+	"shmop":     {},
+	"soap":      {Packages: []string{"libxml2-dev"}},
+	"sockets":   {},
+	"sysvmsg":   {},
+	"sysvsem":   {},
+	"sysvshm":   {},
+	"tidy":      {Packages: []string{"libtidy-dev"}},
+	"xsl":       {Packages: []string{"libxslt1-dev"}},
+	"zip":       {Packages: []string{"libzip-dev"}},
+	"amqp":      {PECL: true, Packages: []string{"librabbitmq-dev"}},
+	"apcu":      {PECL: true},
+	"igbinary":  {PECL: true},
+	"imagick":   {PECL: true, Packages: []string{"libmagickwand-dev"}},
+	"memcached": {PECL: true, Packages: []string{"libmemcached-dev", "libssl-dev", "zlib1g-dev"}},
+	"mongodb":   {PECL: true, Packages: []string{"libssl-dev"}},
+	"redis":     {PECL: true},
+}
+
+// Extensions compiled into the official php images
+var phpBundledExtensions = []string{
+	"core", "ctype", "curl", "date", "dom", "fileinfo", "filter", "hash", "iconv", "json", "libxml",
+	"mbstring", "mysqlnd", "openssl", "pcre", "pdo", "pdo_sqlite", "phar", "posix", "random", "readline",
+	"reflection", "session", "simplexml", "sodium", "spl", "sqlite3", "standard", "tokenizer", "xml",
+	"xmlreader", "xmlwriter", "zlib",
+}
+
+// Returns the sorted extensions to install with docker-php-ext-install and
+// pecl, the Debian packages needed to compile them, and any required
+// extensions that can't be installed automatically. Extra extensions are
+// those a detected framework needs but doesn't declare.
+func findPHPExtensions(path string, extra ...string) ([]string, []string, []string, []string) {
+	required := map[string]bool{}
+	for _, ext := range extra {
+		required[ext] = true
+	}
+	addRequirement := func(name string) {
+		if ext, ok := strings.CutPrefix(strings.ToLower(name), "ext-"); ok {
+			required[strings.TrimPrefix(ext, "zend-")] = true
+		}
+	}
+
+	if composer, err := readComposerJSON(path); err == nil {
+		for name := range composer.Require {
+			addRequirement(name)
+		}
+		for name := range composer.RequireDev {
+			addRequirement(name)
+		}
+	}
+
+	if lock, err := readComposerLock(path); err == nil {
+		for _, pkg := range lock.Packages {
+			for name := range pkg.Require {
+				addRequirement(name)
+			}
+		}
+		for _, platform := range []any{lock.Platform, lock.PlatformDev} {
+			if platform, ok := platform.(map[string]any); ok {
+				for name := range platform {
+					addRequirement(name)
+				}
+			}
+		}
+	}
+
+	extensions := []string{}
+	peclExtensions := []string{}
+	packages := []string{}
+	unknown := []string{}
+	for name := range required {
+		if slices.Contains(phpBundledExtensions, name) {
+			continue
+		}
+
+		ext, ok := phpExtensionMap[name]
+		if !ok {
+			unknown = append(unknown, name)
+			continue
+		}
+
+		if ext.PECL {
+			peclExtensions = append(peclExtensions, name)
+		} else {
+			extensions = append(extensions, name)
+		}
+
+		for _, pkg := range ext.Packages {
+			if !slices.Contains(packages, pkg) {
+				packages = append(packages, pkg)
+			}
+		}
+	}
+
+	slices.Sort(extensions)
+	slices.Sort(peclExtensions)
+	slices.Sort(packages)
+	slices.Sort(unknown)
+	return extensions, peclExtensions, packages, unknown
+}
+
+func isLaravelProject(path string) bool {
+	if _, err := os.Stat(filepath.Join(path, "artisan")); err == nil {
+		return true
+	}
+
+	composer, err := readComposerJSON(path)
+	if err != nil {
+		return false
+	}
+
+	_, ok := composer.Require["laravel/framework"]
+	return ok
+}
+
+func isSymfonyProject(path string) bool {
+	if _, err := os.Stat(filepath.Join(path, "symfony.lock")); err == nil {
+		return true
+	}
+
+	if _, err := os.Stat(filepath.Join(path, "public/index.php")); err != nil {
+		return false
+	}
+
+	composer, err := readComposerJSON(path)
+	if err != nil {
+		return false
+	}
+
+	_, ok := composer.Require["symfony/framework-bundle"]
+	return ok
+}
+
+// Returns the directory WordPress is served from: the project root for a
+// standard install, or web/ for a Bedrock project.
+func findWordPressRoot(path string) (string, bool) {
+	if composer, err := readComposerJSON(path); err == nil {
+		if _, ok := composer.Require["roots/wordpress"]; ok {
+			return "web", true
+		}
+	}
+
+	for _, fn := range []string{"wp-config.php", "wp-config-sample.php", "wp-load.php"} {
+		if _, err := os.Stat(filepath.Join(path, fn)); err == nil {
+			return "", true
+		}
+	}
+
+	return "", false
+}
+
+// Extensions WordPress needs for its database and media handling
+var wordPressExtensions = []string{"exif", "gd", "mysqli", "zip"}
+
 func findPHPVersion(path string, log *slog.Logger) (*string, error) {
 	version := ""
 	versionFiles := []string{
 		".tool-versions",
+		".php-version",
+		".mise.toml",
+		"composer.lock",
 		"composer.json",
 	}
 
@@ -211,31 +644,72 @@ func findPHPVersion(path string, log *slog.Logger) (*string, error) {
 					return nil, fmt.Errorf("Failed to read .tool-versions file")
 				}
 
+			case ".php-version":
+				scanner := bufio.NewScanner(f)
+				for scanner.Scan() {
+					line := strings.TrimSpace(scanner.Text())
+					if line != "" && !strings.HasPrefix(line, "#") {
+						version = resolvePHPVersion(line)
+						log.Info("Detected PHP version in .php-version: " + version)
+						break
+					}
+				}
+
+				if err := scanner.Err(); err != nil {
+					return nil, fmt.Errorf("Failed to read .php-version file")
+				}
+
+			case ".mise.toml":
+				var mise MiseToml
+				if err := toml.NewDecoder(f).Decode(&mise); err != nil {
+					return nil, fmt.Errorf("Failed to decode .mise.toml file")
+				}
+				phpVersion, ok := mise.Tools["php"].(string)
+				if !ok {
+					versions, ok := mise.Tools["php"].([]string)
+					if ok {
+						phpVersion = versions[0]
+					}
+				}
+				if phpVersion != "" {
+					version = resolvePHPVersion(phpVersion)
+					log.Info("Detected PHP version in .mise.toml: " + version)
+				}
+
+			case "composer.lock":
+				var lock composerLock
+				if err := json.NewDecoder(f).Decode(&lock); err != nil {
+					return nil, fmt.Errorf("Failed to read composer.lock file")
+				}
+
+				// config.platform.php pins the version dependencies were resolved for
+				if overrides, ok := lock.PlatformOverrides.(map[string]any); ok {
+					if php, ok := overrides["php"].(string); ok {
+						version = resolvePHPVersion(php)
+						log.Info("Detected PHP version from composer.lock platform-overrides: " + version)
+						break
+					}
+				}
+
+				if platform, ok := lock.Platform.(map[string]any); ok {
+					if php, ok := platform["php"].(string); ok {
+						version = resolvePHPVersion(php)
+						log.Info("Detected PHP version from composer.lock platform: " + version)
+					}
+				}
+
 			case "composer.json":
-				var composerJSON map[string]interface{}
-				err := json.NewDecoder(f).Decode(&composerJSON)
-				if err != nil {
+				var composer composerJSON
+				if err := json.NewDecoder(f).Decode(&composer); err != nil {
 					return nil, fmt.Errorf("Failed to read composer.json file")
 				}
 
-				if require, ok := composerJSON["require"].(map[string]interface{}); ok {
-					if php, ok := require["php"].(string); ok {
-						// Version can be a range, e.g. ">=7.2" so we need to extract the version
-						if gteVersionRe.MatchString(php) {
-							version = gteVersionRe.FindStringSubmatch(php)[1]
-						} else if rangeVersionRe.MatchString(php) {
-							version = rangeVersionRe.FindStringSubmatch(php)[2]
-						} else if tildeVersionRe.MatchString(php) {
-							version = tildeVersionRe.FindStringSubmatch(php)[1]
-						} else if caretVersionRe.MatchString(php) {
-							version = caretVersionRe.FindStringSubmatch(php)[1]
-						} else if exactVersionRe.MatchString(php) {
-							version = exactVersionRe.FindStringSubmatch(php)[1]
-						}
-
-						version = strings.TrimSuffix(version, ".")
-						log.Info("Detected PHP version from composer.json: " + version)
-					}
+				if php, ok := composer.Config.Platform["php"].(string); ok {
+					version = resolvePHPVersion(php)
+					log.Info("Detected PHP version from composer.json config.platform: " + version)
+				} else if php := composer.Require["php"]; php != "" {
+					version = resolvePHPVersion(php)
+					log.Info("Detected PHP version from composer.json: " + version)
 				}
 			}
 
@@ -254,6 +728,74 @@ func findPHPVersion(path string, log *slog.Logger) (*string, error) {
 	return &version, nil
 }
 
+// PHP minor releases with official images, newest first.
+var phpVersions = []string{"8.5", "8.4", "8.3", "8.2", "8.1", "8.0", "7.4", "7.3", "7.2", "7.1", "7.0", "5.6"}
+
+// Returns the newest PHP minor release allowed by a Composer version
+// constraint. Exact versions are returned as-is.
+func resolvePHPVersion(constraint string) string {
+	constraint = strings.TrimSpace(constraint)
+	if exactPHPVersionRe.MatchString(constraint) {
+		return constraint
+	}
+
+	constraints, err := semver.NewConstraint(composerToSemver(constraint))
+	if err != nil {
+		return strings.TrimSuffix(exactVersionRe.FindString(strings.TrimLeft(constraint, "=<>~^! ")), ".")
+	}
+
+	for _, minor := range phpVersions {
+		// Check the patch releases of each minor so that constraints like
+		// ">=8.1.12" still match the 8.1 image
+		for patch := 0; patch < 50; patch++ {
+			if constraints.Check(semver.MustParse(fmt.Sprintf("%s.%d", minor, patch))) {
+				return minor
+			}
+		}
+	}
+
+	return strings.TrimSuffix(exactVersionRe.FindString(strings.TrimLeft(constraint, "=<>~^! ")), ".")
+}
+
+var exactPHPVersionRe = regexp.MustCompile(`^\d+\.\d+(\.\d+)?$`)
+
+// Translates Composer constraints to the constraint syntax used by semver.
+func composerToSemver(constraint string) string {
+	constraint = stabilityFlagRe.ReplaceAllString(constraint, "")
+	constraint = operatorSpaceRe.ReplaceAllString(constraint, "$1")
+	groups := strings.Split(strings.ReplaceAll(constraint, "||", "|"), "|")
+	for i, group := range groups {
+		// 8.0 - 8.2 allows any 8.2.x release when the upper bound is partial
+		group = hyphenRangeRe.ReplaceAllStringFunc(group, func(r string) string {
+			bounds := hyphenRangeRe.FindStringSubmatch(r)
+			upper := strings.Split(bounds[2], ".")
+			if len(upper) == 2 {
+				minor, _ := strconv.Atoi(upper[1])
+				return fmt.Sprintf(">=%s,<%s.%d", bounds[1], upper[0], minor+1)
+			}
+			return fmt.Sprintf(">=%s,<=%s", bounds[1], bounds[2])
+		})
+
+		clauses := strings.FieldsFunc(group, func(r rune) bool { return r == ',' || r == ' ' })
+		for j, clause := range clauses {
+			// ~8.1 allows any 8.x release from 8.1, while ~8.1.2 allows 8.1.x releases
+			if version, ok := strings.CutPrefix(clause, "~"); ok && strings.Count(version, ".") == 1 {
+				major, _ := strconv.Atoi(strings.Split(version, ".")[0])
+				clause = fmt.Sprintf(">=%s, <%d", version, major+1)
+			}
+			clauses[j] = clause
+		}
+
+		groups[i] = strings.Join(clauses, ", ")
+	}
+
+	return strings.Join(groups, " || ")
+}
+
+var stabilityFlagRe = regexp.MustCompile(`@\w+`)
+var operatorSpaceRe = regexp.MustCompile(`([<>=!~^]+)\s+`)
+var hyphenRangeRe = regexp.MustCompile(`([\d.]+)\s+-\s+([\d.]+)`)
+
 var gteVersionRe = regexp.MustCompile(`^>=\s*([\d.]+)`)
 var rangeVersionRe = regexp.MustCompile(`^([\d.]+)\s*-\s*([\d.]+)`)
 var tildeVersionRe = regexp.MustCompile(`^~\s*([\d.]+)`)
@@ -29,6 +29,11 @@ func TestPHPMatch(t *testing.T) {
 			path:     "../testdata/php-npm",
 			expected: true,
 		},
+		{
+			name:     "PHP project with Laravel",
+			path:     "../testdata/php-laravel",
+			expected: true,
+		},
 		{
 			name:     "Not a PHP project",
 			path:     "../testdata/deno",
@@ -56,17 +61,17 @@ func TestPHPGenerateDockerfile(t *testing.T) {
 		{
 			name:     "PHP project",
 			path:     "../testdata/php",
-			expected: []any{`ARG VERSION=8.3`, regexp.MustCompile(`^ARG BUILD_CMD=$`), `ARG START_CMD="apache2-foreground`},
+			expected: []any{`ARG VERSION=8.3`, regexp.MustCompile(`^ARG BUILD_CMD=$`), `ARG SERVER=apache`, `ENV SERVER_CMD=apache2-foreground`, regexp.MustCompile(`^ARG START_CMD=$`), regexp.MustCompile(`^ARG DOCUMENT_ROOT=$`), regexp.MustCompile(`^ARG WORKER_CMD=$`), regexp.MustCompile(`^ARG PHP_EXTENSIONS=$`)},
 		},
 		{
 			name:     "PHP project with composer",
 			path:     "../testdata/php-composer",
-			expected: []any{`ARG VERSION=5.3`, `ARG INSTALL_CMD="composer update && composer install --prefer-dist --no-dev --optimize-autoloader --no-interaction"`, regexp.MustCompile(`^ARG BUILD_CMD=$`), `ARG START_CMD="apache2-foreground`},
+			expected: []any{`ARG VERSION=8.5`, `ARG INSTALL_CMD="composer install --prefer-dist --no-dev --optimize-autoloader --no-interaction --ignore-platform-req=php --ignore-platform-req=ext-*"`, `COPY composer.json composer.lock* ./`, `RUN --mount=type=cache,target=/tmp/composer-cache composer install --prefer-dist --no-dev --no-scripts --no-autoloader --no-interaction --ignore-platform-req=php --ignore-platform-req=ext-*`, regexp.MustCompile(`^ARG BUILD_CMD=$`), `ARG SERVER=apache`, `ENV SERVER_CMD=apache2-foreground`, regexp.MustCompile(`^ARG START_CMD=$`)},
 		},
 		{
 			name:     "PHP project with NPM",
 			path:     "../testdata/php-npm",
-			expected: []any{`ARG VERSION=8.2.0`, `ARG INSTALL_CMD="yarn --frozen-lockfile"`, `ARG BUILD_CMD="yarn run build"`, `ARG START_CMD="apache2-foreground`},
+			expected: []any{`ARG VERSION=8.2.0`, `ARG INSTALL_CMD="yarn --frozen-lockfile"`, `ARG BUILD_CMD="yarn run build"`, `ARG SERVER=apache`, `ENV SERVER_CMD=apache2-foreground`, regexp.MustCompile(`^ARG START_CMD=$`)},
 		},
 		{
 			name: "PHP project with build mounts",
@@ -82,10 +87,89 @@ func TestPHPGenerateDockerfile(t *testing.T) {
     `},
 			expected: []any{regexp.MustCompile(`^RUN --mount=type=secret,id=_env,target=/app/.env \\$`)},
 		},
+		{
+			name: "PHP project with Laravel",
+			path: "../testdata/php-laravel",
+			expected: []any{
+				`ARG DOCUMENT_ROOT="public"`,
+				`ARG OPTIMIZE_CMD="php artisan config:cache && php artisan route:cache && php artisan view:cache"`,
+				`ARG RELEASE_CMD="php artisan migrate --force"`,
+				`ARG WORKER_CMD="php artisan queue:work --tries=3"`,
+				`ARG SCHEDULER_CMD="php artisan schedule:work"`,
+				`then exec ${WORKER_CMD:?WORKER_CMD is not set}; elif [ "${PROCESS}" = "scheduler" ]; then exec ${SCHEDULER_CMD:?SCHEDULER_CMD is not set};`,
+				`chmod -R ug+rwX storage bootstrap/cache`,
+			},
+		},
+		{
+			name:     "PHP project with a version range",
+			path:     "../testdata/php-constraint-range",
+			expected: []any{`ARG VERSION=8.3`},
+		},
+		{
+			name:     "PHP project with a version union",
+			path:     "../testdata/php-constraint-union",
+			expected: []any{`ARG VERSION=8.0`},
+		},
+		{
+			name:     "PHP project with platform overrides",
+			path:     "../testdata/php-platform-overrides",
+			expected: []any{`ARG VERSION=8.2.12`},
+		},
+		{
+			name:     "PHP project with .php-version",
+			path:     "../testdata/php-version-file",
+			expected: []any{`ARG VERSION=8.1`},
+		},
+		{
+			name: "PHP project with nginx",
+			path: "../testdata/php",
+			data: map[string]string{"Server": "nginx"},
+			expected: []any{
+				`ARG SERVER=nginx`,
+				`FROM php:${VERSION}-fpm AS runtime-nginx`,
+				`ENV SERVER_CMD=nginx-php-fpm`,
+				`include /etc/nginx/fastcgi_params;`,
+				`FROM runtime-${SERVER} AS runtime`,
+			},
+		},
+		{
+			name: "PHP project with FrankenPHP",
+			path: "../testdata/php-symfony",
+			data: map[string]string{"Server": "frankenphp"},
+			expected: []any{
+				`ARG SERVER=frankenphp`,
+				`FROM docker.io/dunglas/frankenphp:php${VERSION} AS runtime-frankenphp`,
+				`ENV START_CMD=${START_CMD:-${SERVER_CMD}}`,
+			},
+		},
+		{
+			name:     "PHP project with Symfony",
+			path:     "../testdata/php-symfony",
+			expected: []any{`ARG VERSION=8.5`, `ARG DOCUMENT_ROOT="public"`, `ENV SERVER_ROOT=/var/www/html/${DOCUMENT_ROOT}`},
+		},
+		{
+			name:     "PHP project with WordPress",
+			path:     "../testdata/php-wordpress",
+			expected: []any{regexp.MustCompile(`^ARG DOCUMENT_ROOT=$`), `ARG PHP_EXTENSIONS="exif gd mysqli zip"`},
+		},
+		{
+			name:     "PHP project with Bedrock",
+			path:     "../testdata/php-bedrock",
+			expected: []any{`ARG DOCUMENT_ROOT="web"`, `ARG PHP_EXTENSIONS="exif gd mysqli zip"`},
+		},
+		{
+			name: "PHP project with extensions",
+			path: "../testdata/php-extensions",
+			expected: []any{
+				`ARG PHP_EXTENSIONS="gd intl opcache pdo_pgsql zip"`,
+				`ARG PECL_EXTENSIONS="redis"`,
+				`ARG EXTENSION_PACKAGES="libfreetype-dev libicu-dev libjpeg62-turbo-dev libpng-dev libpq-dev libwebp-dev libzip-dev"`,
+			},
+		},
 		{
 			name:     "Not a PHP project",
 			path:     "../testdata/deno",
-			expected: []any{`ARG VERSION=8.3`, regexp.MustCompile(`^ARG INSTALL_CMD=$`), regexp.MustCompile(`^ARG BUILD_CMD=$`), `ARG START_CMD="apache2-foreground`},
+			expected: []any{`ARG VERSION=8.3`, regexp.MustCompile(`^ARG INSTALL_CMD=$`), regexp.MustCompile(`^ARG BUILD_CMD=$`), `ARG SERVER=apache`, `ENV SERVER_CMD=apache2-foreground`, regexp.MustCompile(`^ARG START_CMD=$`)},
 		},
 	}
 
@@ -3,14 +3,19 @@ package runtime
 import (
 	"bufio"
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"log/slog"
 	"maps"
 	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
 	"text/template"
 
+	"github.com/Masterminds/semver/v3"
 	"github.com/pelletier/go-toml"
 )
 
@@ -70,19 +75,19 @@ func (d *Python) GenerateDockerfile(path string, data ...map[string]string) ([]b
 		installCMD = "pip install --no-cache -r requirements.txt"
 	} else if _, err := os.Stat(filepath.Join(path, "uv.lock")); err == nil {
 		d.Log.Info("Detected a uv project")
-		installCMD = "pip install uv && uv sync --python-preference=only-system --no-cache --no-dev"
+		installCMD = "uv sync --python-preference=only-system --no-cache --no-dev"
 		packageManager = PythonPackageManagerUv
 	} else if _, err := os.Stat(filepath.Join(path, "poetry.lock")); err == nil {
 		d.Log.Info("Detected a poetry project")
-		installCMD = "pip install poetry && poetry install --no-dev --no-ansi --no-root"
+		installCMD = "poetry install --no-dev --no-ansi --no-root"
 		packageManager = PythonPackageManagerPoetry
 	} else if _, err := os.Stat(filepath.Join(path, "Pipfile.lock")); err == nil {
 		d.Log.Info("Detected a pipenv project")
-		installCMD = "pip install pipenv && pipenv install --dev --system --deploy"
+		installCMD = "pipenv install --dev --system --deploy"
 		packageManager = PythonPackageManagerPipenv
 	} else if _, err := os.Stat(filepath.Join(path, "pdm.lock")); err == nil {
 		d.Log.Info("Detected a pdm project")
-		installCMD = "pip install pdm && pdm install --prod"
+		installCMD = "pdm install --prod"
 		packageManager = PythonPackageManagerPdm
 	} else if _, err := os.Stat(filepath.Join(path, "pyproject.toml")); err == nil {
 		d.Log.Info("Detected a pyproject.toml file")
@@ -91,35 +96,74 @@ func (d *Python) GenerateDockerfile(path string, data ...map[string]string) ([]b
 
 	managePy := isDjangoProject(path)
 	isFastAPI := isFastAPIProject(path)
+	packages := findPythonPackages(path)
 	startCMD := ""
-	projectName := filepath.Base(path)
 
+	pyproject, err := readPyprojectTOML(path)
+	if err != nil {
+		d.Log.Warn(err.Error() + ". Continuing without it")
+		pyproject = &pyprojectTOML{}
+	}
+
+	if app := findPythonApp(path, managePy); app != nil {
+		startCMD = pythonServerCommand(*app, packages)
+		if startCMD != "" {
+			d.Log.Info("Detected start command via " + app.Interface + " application " + app.Module + ":" + app.Variable)
+		}
+	}
+
+	appInstructions := ""
+	if startCMD == "" {
+		startCMD, appInstructions = findPythonDataApp(path, packages)
+		if startCMD != "" {
+			d.Log.Info("Detected start command via data app: " + startCMD)
+		}
+	}
+
+	buildCMD := ""
+	releaseCMD := ""
 	if managePy != nil {
+		if packages["whitenoise"] {
+			d.Log.Info("Detected whitenoise")
+		}
+
+		if hasDjangoStaticRoot(path, *managePy) {
+			d.Log.Info("Detected STATIC_ROOT in Django settings")
+			buildCMD = "python " + *managePy + " collectstatic --noinput"
+		} else if packages["whitenoise"] {
+			d.Log.Warn("whitenoise requires STATIC_ROOT to be configured in Django settings")
+		}
+
+		releaseCMD = "python " + *managePy + " migrate"
+	}
+
+	if startCMD == "" && managePy != nil {
 		d.Log.Info("Detected Django project")
 		startCMD = fmt.Sprintf(`python ` + *managePy + ` runserver 0.0.0.0:${PORT}`)
-	} else if !isFastAPI {
-		if _, err := os.Stat(filepath.Join(path, "pyproject.toml")); err == nil {
-			f, err := os.Open(filepath.Join(path, "pyproject.toml"))
-			if err == nil {
-				var pyprojectTOML map[string]interface{}
-				err := toml.NewDecoder(f).Decode(&pyprojectTOML)
-				if err == nil {
-					if project, ok := pyprojectTOML["project"].(map[string]interface{}); ok {
-						if name, ok := project["name"].(string); ok {
-							projectName = name
-						}
-					} else if project, ok := pyprojectTOML["tool.poetry"].(map[string]interface{}); ok {
-						if name, ok := project["name"].(string); ok {
-							projectName = name
-						}
-					}
-				}
+	} else if startCMD == "" && pyproject != nil {
+		// Console scripts are only available when the project itself is installed
+		installsProject := false
+		switch packageManager {
+		case PythonPackageManagerUv, PythonPackageManagerPoetry, PythonPackageManagerPdm:
+			installsProject = true
+		case PythonPackageManagerPip:
+			// pip only installs the project itself when there's no requirements.txt
+			_, err := os.Stat(filepath.Join(path, "requirements.txt"))
+			installsProject = err != nil
+		}
 
-				if projectName != "" {
-					startCMD = fmt.Sprintf(`python -m %s`, projectName)
-					d.Log.Info("Detected start command via pyproject.toml")
-				}
-			}
+		if script := pyproject.script(); script != "" && installsProject {
+			startCMD = script
+			d.Log.Info("Detected start command via console script in pyproject.toml: " + startCMD)
+		} else if module := pyproject.module(path, installsProject); module != "" && !isFastAPI {
+			startCMD = fmt.Sprintf(`python -m %s`, module)
+			d.Log.Info("Detected start command via pyproject.toml")
+		}
+
+		// Poetry skips installing the project with --no-root, which is needed
+		// for console scripts and src-layout packages
+		if packageManager == PythonPackageManagerPoetry && startCMD != "" {
+			installCMD = strings.Replace(installCMD, " --no-root", "", 1)
 		}
 	}
 
@@ -152,30 +196,57 @@ func (d *Python) GenerateDockerfile(path string, data ...map[string]string) ([]b
 		}
 	}
 
+	workerCMD, beatCMD := findPythonWorker(path, packages)
+	if workerCMD != "" {
+		d.Log.Info("Detected background worker: " + workerCMD)
+	}
+
+	buildPackages, runtimePackages := findSystemPackages(pythonSystemPackageMap, packages)
+	if len(buildPackages) > 0 || len(runtimePackages) > 0 {
+		d.Log.Info("Detected Python packages that require system libraries")
+	}
+
 	packagerInstructions := ""
 	switch packageManager {
 	case PythonPackageManagerPoetry:
 		packagerInstructions = poetryInstructions
 	case PythonPackageManagerUv:
 		packagerInstructions = uvInstructions
+	case PythonPackageManagerPipenv:
+		packagerInstructions = pipenvInstructions
+	case PythonPackageManagerPdm:
+		packagerInstructions = pdmInstructions
 	}
 
 	d.Log.Info(
 		fmt.Sprintf(`Detected defaults 
   Python version       : %s
   Install command      : %s
+  Build command        : %s
   Start command        : %s
+  Release command      : %s
+  Worker command       : %s
+  Beat command         : %s
+  Build packages       : %s
+  Runtime packages     : %s
 
   Docker build arguments can supersede these defaults if provided.
-  See https://flexstack.com/docs/languages-and-frameworks/autogenerate-dockerfile`, *version, installCMD, startCMD),
+  See https://flexstack.com/docs/languages-and-frameworks/autogenerate-dockerfile`, *version, installCMD, buildCMD, startCMD, releaseCMD, workerCMD, beatCMD, strings.Join(buildPackages, " "), strings.Join(runtimePackages, " ")),
 	)
 
 	var buf bytes.Buffer
 	templateData := map[string]string{
 		"Version":              *version,
 		"InstallCMD":           safeCommand(installCMD),
+		"BuildCMD":             safeCommand(buildCMD),
 		"StartCMD":             safeCommand(startCMD),
+		"ReleaseCMD":           safeCommand(releaseCMD),
+		"WorkerCMD":            safeCommand(workerCMD),
+		"BeatCMD":              safeCommand(beatCMD),
+		"BuildPackages":        safeCommand(strings.Join(buildPackages, " ")),
+		"RuntimePackages":      safeCommand(strings.Join(runtimePackages, " ")),
 		"PackagerInstructions": packagerInstructions,
+		"AppInstructions":      appInstructions,
 	}
 	if len(data) > 0 {
 		maps.Copy(templateData, data[0])
@@ -190,45 +261,103 @@ func (d *Python) GenerateDockerfile(path string, data ...map[string]string) ([]b
 var pythonTemplate = strings.TrimSpace(`
 ARG VERSION={{.Version}}
 ARG BUILDER=docker.io/library/python
-FROM ${BUILDER}:${VERSION}-slim
+FROM ${BUILDER}:${VERSION} AS build
 WORKDIR /app
-RUN apt-get update && apt-get install -y --no-install-recommends wget ca-certificates && apt-get clean && rm -f /var/lib/apt/lists/*_*
-RUN update-ca-certificates 2>/dev/null || true
-RUN addgroup --system nonroot && adduser --system --ingroup nonroot nonroot
-RUN chown -R nonroot:nonroot /app
-RUN mkdir -p /var/cache
-RUN chown -R nonroot:nonroot /var/cache
+# Headers and tools needed to compile packages that link against system libraries
+ARG BUILD_PACKAGES={{.BuildPackages}}
+RUN if [ ! -z "${BUILD_PACKAGES}" ]; then apt-get update && apt-get install -y --no-install-recommends ${BUILD_PACKAGES} && apt-get clean && rm -f /var/lib/apt/lists/*_*; fi
 
 ENV PYTHONDONTWRITEBYTECODE=1
 ENV PYTHONUNBUFFERED=1
 {{ .PackagerInstructions }}
 
-COPY --chown=nonroot:nonroot . .
+# Dependencies are installed into a virtual environment that is copied into the runtime image,
+# leaving compilers and package managers behind in the build stage
+ENV VIRTUAL_ENV=/opt/venv
+RUN python -m venv ${VIRTUAL_ENV}
+ENV PATH="${VIRTUAL_ENV}/bin:$PATH"
+
+COPY . .
 ARG INSTALL_CMD={{.InstallCMD}}
 RUN if [ ! -z "${INSTALL_CMD}" ]; then sh -c "$INSTALL_CMD";  fi
+ARG BUILD_CMD={{.BuildCMD}}
+RUN if [ ! -z "${BUILD_CMD}" ]; then sh -c "$BUILD_CMD"; fi
+
+FROM ${BUILDER}:${VERSION}-slim AS runtime
+WORKDIR /app
+# Shared libraries needed by compiled packages at runtime
+ARG RUNTIME_PACKAGES={{.RuntimePackages}}
+RUN apt-get update && apt-get install -y --no-install-recommends wget ca-certificates ${RUNTIME_PACKAGES} && apt-get clean && rm -f /var/lib/apt/lists/*_*
+RUN update-ca-certificates 2>/dev/null || true
+RUN addgroup --system nonroot && adduser --system --ingroup nonroot nonroot
+RUN chown -R nonroot:nonroot /app
+
+ENV PYTHONDONTWRITEBYTECODE=1
+ENV PYTHONUNBUFFERED=1
+ENV VIRTUAL_ENV=/opt/venv
+ENV PATH="${VIRTUAL_ENV}/bin:$PATH"
+
+COPY --from=build --chown=nonroot:nonroot /opt/venv /opt/venv
+COPY --from=build --chown=nonroot:nonroot /app /app
 
 ENV PORT=8080
 EXPOSE ${PORT}
+{{ .AppInstructions }}
+# The number of worker processes used by gunicorn, uvicorn, hypercorn and granian
+ARG WEB_CONCURRENCY=2
+ENV WEB_CONCURRENCY=${WEB_CONCURRENCY}
 USER nonroot:nonroot
 
+# An optional command, e.g. database migrations, to run before a new release receives traffic
+ARG RELEASE_CMD={{.ReleaseCMD}}
+ENV RELEASE_CMD=${RELEASE_CMD}
 ARG START_CMD={{.StartCMD}}
 ENV START_CMD=${START_CMD}
 RUN if [ -z "${START_CMD}" ]; then echo "Unable to detect a container start command" && exit 1; fi
-CMD ${START_CMD}
+
+# The same image can run background processes by setting PROCESS to "worker" or "beat"
+ARG WORKER_CMD={{.WorkerCMD}}
+ENV WORKER_CMD=${WORKER_CMD}
+ARG BEAT_CMD={{.BeatCMD}}
+ENV BEAT_CMD=${BEAT_CMD}
+ARG PROCESS=web
+ENV PROCESS=${PROCESS}
+CMD if [ "${PROCESS}" = "worker" ]; then exec ${WORKER_CMD:?WORKER_CMD is not set}; elif [ "${PROCESS}" = "beat" ]; then exec ${BEAT_CMD:?BEAT_CMD is not set}; else exec ${START_CMD}; fi
 `)
 
+var streamlitInstructions = `
+# Run without prompts and don't collect usage statistics in the container
+ENV STREAMLIT_SERVER_HEADLESS=true
+ENV STREAMLIT_BROWSER_GATHER_USAGE_STATS=false`
+
+var gradioInstructions = `
+ENV GRADIO_ANALYTICS_ENABLED=False`
+
+var dashInstructions = `
+# Dash's app.run() binds to the HOST and PORT environment variables
+ENV HOST=0.0.0.0`
+
+// Package managers are installed into the build stage's system Python so that
+// they aren't copied into the runtime image with the virtual environment.
 var poetryInstructions = `
 ENV POETRY_NO_INTERACTION=1
 ENV POETRY_VIRTUALENVS_CREATE=false
 ENV POETRY_CACHE_DIR='/var/cache/pypoetry'
-ENV POETRY_HOME='/usr/local'`
+ENV POETRY_HOME='/usr/local'
+RUN pip install --no-cache poetry`
 
 var uvInstructions = `
 # Set the UV_CACHE_DIR environment variable to a directory where uv will store its cache
 ENV UV_CACHE_DIR='/var/cache/uv'
-# Use the virtual environment automatically
-ENV VIRTUAL_ENV=/app/.venv
-ENV PATH="/app/.venv/bin:$PATH"`
+# Sync into the virtual environment that is copied into the runtime image
+ENV UV_PROJECT_ENVIRONMENT=/opt/venv
+RUN pip install --no-cache uv`
+
+var pipenvInstructions = `
+RUN pip install --no-cache pipenv`
+
+var pdmInstructions = `
+RUN pip install --no-cache pdm`
 
 func findPythonVersion(path string, log *slog.Logger) (*string, error) {
 	version := ""
@@ -237,6 +366,8 @@ func findPythonVersion(path string, log *slog.Logger) (*string, error) {
 		".python-version",
 		".mise.toml",
 		"runtime.txt",
+		"pyproject.toml",
+		"Pipfile",
 	}
 
 	for _, file := range versionFiles {
@@ -267,11 +398,13 @@ func findPythonVersion(path string, log *slog.Logger) (*string, error) {
 				}
 
 			case ".python-version":
+				// The file may list several versions, e.g. for uv or pyenv, in which
+				// case the first one is the project's primary version
 				scanner := bufio.NewScanner(f)
 				for scanner.Scan() {
-					line := scanner.Text()
-					if line != "" {
-						version = line
+					line := strings.TrimSpace(scanner.Text())
+					if line != "" && !strings.HasPrefix(line, "#") {
+						version = strings.TrimPrefix(strings.TrimPrefix(line, "cpython-"), "cpython@")
 						log.Info("Detected Python version from .python-version: " + version)
 						break
 					}
@@ -313,6 +446,43 @@ func findPythonVersion(path string, log *slog.Logger) (*string, error) {
 					log.Info("Detected Python version in .mise.toml: " + version)
 					break
 				}
+
+			case "pyproject.toml":
+				var pyproject pyprojectTOML
+				if err := toml.NewDecoder(f).Decode(&pyproject); err != nil {
+					log.Warn("Failed to decode pyproject.toml file")
+					break
+				}
+
+				requiresPython := pyproject.Project.RequiresPython
+				if requiresPython == "" {
+					requiresPython, _ = pyproject.Tool.Poetry.Dependencies["python"].(string)
+				}
+
+				if requiresPython != "" {
+					version = resolvePythonVersion(requiresPython)
+					log.Info("Detected Python version in pyproject.toml: " + version)
+				}
+
+			case "Pipfile":
+				var pipfile struct {
+					Requires struct {
+						PythonVersion     string `toml:"python_version"`
+						PythonFullVersion string `toml:"python_full_version"`
+					} `toml:"requires"`
+				}
+				if err := toml.NewDecoder(f).Decode(&pipfile); err != nil {
+					log.Warn("Failed to decode Pipfile")
+					break
+				}
+
+				version = pipfile.Requires.PythonFullVersion
+				if version == "" {
+					version = pipfile.Requires.PythonVersion
+				}
+				if version != "" {
+					log.Info("Detected Python version in Pipfile: " + version)
+				}
 			}
 
 			f.Close()
@@ -330,6 +500,64 @@ func findPythonVersion(path string, log *slog.Logger) (*string, error) {
 	return &version, nil
 }
 
+// Python minor releases with official images, newest first.
+var pythonVersions = []string{"3.14", "3.13", "3.12", "3.11", "3.10", "3.9", "3.8", "3.7"}
+
+// Resolves a version specifier, e.g. ">=3.10,<3.12" or "^3.11", to the newest
+// Python minor release that satisfies it.
+func resolvePythonVersion(specifier string) string {
+	specifier = strings.TrimSpace(specifier)
+	if _, err := semver.StrictNewVersion(specifier); err == nil || exactPythonVersionRe.MatchString(specifier) {
+		return specifier
+	}
+
+	constraints, err := semver.NewConstraint(pep440ToSemver(specifier))
+	if err != nil {
+		return strings.TrimSuffix(exactVersionRe.FindString(strings.TrimLeft(specifier, "=<>~^! ")), ".")
+	}
+
+	for _, minor := range pythonVersions {
+		// Check the patch releases of each minor so that specifiers like
+		// ">=3.11.4" still match the 3.11 image
+		for patch := 0; patch < 30; patch++ {
+			if constraints.Check(semver.MustParse(fmt.Sprintf("%s.%d", minor, patch))) {
+				return minor
+			}
+		}
+	}
+
+	return strings.TrimSuffix(exactVersionRe.FindString(strings.TrimLeft(specifier, "=<>~^! ")), ".")
+}
+
+var exactPythonVersionRe = regexp.MustCompile(`^\d+\.\d+$`)
+
+// Translates PEP 440 specifiers to the constraint syntax used by semver.
+func pep440ToSemver(specifier string) string {
+	clauses := strings.Split(specifier, ",")
+	for i, clause := range clauses {
+		clause = strings.TrimSpace(clause)
+		switch {
+		case strings.HasPrefix(clause, "~="):
+			// ~=3.10 allows any 3.x release from 3.10, while ~=3.10.2 allows 3.10.x releases
+			version := strings.TrimSpace(strings.TrimPrefix(clause, "~="))
+			if strings.Count(version, ".") == 1 {
+				major, _ := strconv.Atoi(strings.Split(version, ".")[0])
+				clause = fmt.Sprintf(">=%s, <%d", version, major+1)
+			} else {
+				clause = "~" + version
+			}
+		case strings.HasPrefix(clause, "==="):
+			clause = "=" + strings.TrimPrefix(clause, "===")
+		case strings.HasPrefix(clause, "=="):
+			clause = "=" + strings.TrimPrefix(clause, "==")
+		}
+
+		clauses[i] = clause
+	}
+
+	return strings.Join(clauses, ", ")
+}
+
 func isDjangoProject(path string) *string {
 	manageFiles := []string{"manage.py", "app/manage.py", filepath.Join(filepath.Base(path), "manage.py")}
 	var managePy *string
@@ -372,6 +600,35 @@ func isDjangoProject(path string) *string {
 	return nil
 }
 
+// Returns true if the Django settings next to manage.py configure STATIC_ROOT,
+// which is required to run collectstatic.
+func hasDjangoStaticRoot(path string, managePy string) bool {
+	dir := filepath.Join(path, filepath.Dir(managePy))
+	settingsFiles, _ := filepath.Glob(filepath.Join(dir, "*", "settings.py"))
+	settingsModules, _ := filepath.Glob(filepath.Join(dir, "*", "settings", "*.py"))
+
+	for _, file := range append(settingsFiles, settingsModules...) {
+		f, err := os.Open(file)
+		if err != nil {
+			continue
+		}
+
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			if staticRootRe.MatchString(scanner.Text()) {
+				f.Close()
+				return true
+			}
+		}
+
+		f.Close()
+	}
+
+	return false
+}
+
+var staticRootRe = regexp.MustCompile(`^STATIC_ROOT\s*=`)
+
 func isFastAPIProject(path string) bool {
 	packagerFiles := []string{"requirements.txt", "pyproject.toml", "Pipfile"}
 
@@ -400,6 +657,461 @@ func isFastAPIProject(path string) bool {
 	return false
 }
 
+// Returns the normalized names of the packages declared in the project's
+// dependency and lock files. Optional dependencies and extras are left out.
+func findPythonPackages(path string) map[string]bool {
+	packages := map[string]bool{}
+
+	if f, err := os.Open(filepath.Join(path, "requirements.txt")); err == nil {
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			if match := pythonPackageRe.FindStringSubmatch(strings.TrimSpace(scanner.Text())); match != nil {
+				packages[normalizePythonPackage(match[1])] = true
+			}
+		}
+
+		f.Close()
+	}
+
+	if pyproject, err := readPyprojectTOML(path); err == nil && pyproject != nil {
+		for _, dependency := range pyproject.Project.Dependencies {
+			if match := pythonPackageRe.FindStringSubmatch(strings.TrimSpace(dependency)); match != nil {
+				packages[normalizePythonPackage(match[1])] = true
+			}
+		}
+
+		for name := range pyproject.Tool.Poetry.Dependencies {
+			if name != "python" {
+				packages[normalizePythonPackage(name)] = true
+			}
+		}
+	}
+
+	if contents, err := os.ReadFile(filepath.Join(path, "Pipfile")); err == nil {
+		var pipfile struct {
+			Packages map[string]interface{} `toml:"packages"`
+		}
+		if err := toml.Unmarshal(contents, &pipfile); err == nil {
+			for name := range pipfile.Packages {
+				packages[normalizePythonPackage(name)] = true
+			}
+		}
+	}
+
+	// Lock files list every installed package, including transitive
+	// dependencies, in [[package]] tables
+	for _, file := range []string{"poetry.lock", "pdm.lock", "uv.lock"} {
+		contents, err := os.ReadFile(filepath.Join(path, file))
+		if err != nil {
+			continue
+		}
+
+		var lock struct {
+			Package []struct {
+				Name string `toml:"name"`
+				// Set by Poetry for packages only installed with one of the project's extras
+				Optional bool `toml:"optional"`
+			} `toml:"package"`
+		}
+		if err := toml.Unmarshal(contents, &lock); err != nil {
+			continue
+		}
+
+		for _, pkg := range lock.Package {
+			if !pkg.Optional {
+				packages[normalizePythonPackage(pkg.Name)] = true
+			}
+		}
+	}
+
+	if contents, err := os.ReadFile(filepath.Join(path, "Pipfile.lock")); err == nil {
+		var pipfileLock struct {
+			Default map[string]interface{} `json:"default"`
+		}
+		if err := json.Unmarshal(contents, &pipfileLock); err == nil {
+			for name := range pipfileLock.Default {
+				packages[normalizePythonPackage(name)] = true
+			}
+		}
+	}
+
+	return packages
+}
+
+// Maps Python packages to the Debian packages they need when they are built
+// from source or imported. Package names target the Debian release used by the
+// official Python images.
+var pythonSystemPackageMap = map[string]systemPackages{
+	"psycopg2":     {Build: []string{"libpq-dev"}, Runtime: []string{"libpq5"}},
+	"psycopg":      {Runtime: []string{"libpq5"}},
+	"mysqlclient":  {Build: []string{"default-libmysqlclient-dev", "pkg-config"}, Runtime: []string{"libmariadb3"}},
+	"pillow":       {Build: []string{"libjpeg62-turbo-dev", "zlib1g-dev", "libfreetype6-dev"}, Runtime: []string{"libjpeg62-turbo", "zlib1g", "libfreetype6"}},
+	"lxml":         {Build: []string{"libxml2-dev", "libxslt1-dev"}, Runtime: []string{"libxml2", "libxslt1.1"}},
+	"python-ldap":  {Build: []string{"libldap2-dev", "libsasl2-dev"}, Runtime: []string{"libldap2", "libsasl2-2"}},
+	"weasyprint":   {Runtime: []string{"libpango-1.0-0", "libpangoft2-1.0-0", "libharfbuzz-subset0"}},
+	"pyodbc":       {Build: []string{"unixodbc-dev"}, Runtime: []string{"unixodbc"}},
+	"python-magic": {Runtime: []string{"libmagic1"}},
+}
+
+func normalizePythonPackage(name string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(strings.ToLower(name), "_", "-"), ".", "-")
+}
+
+var pythonPackageRe = regexp.MustCompile(`^"?([A-Za-z0-9][A-Za-z0-9._-]*)`)
+
+type pyprojectTOML struct {
+	Project struct {
+		Name           string            `toml:"name"`
+		RequiresPython string            `toml:"requires-python"`
+		Dependencies   []string          `toml:"dependencies"`
+		Scripts        map[string]string `toml:"scripts"`
+	} `toml:"project"`
+	Tool struct {
+		Poetry struct {
+			Name         string                 `toml:"name"`
+			Dependencies map[string]interface{} `toml:"dependencies"`
+			Scripts      map[string]interface{} `toml:"scripts"`
+			Packages     []struct {
+				Include string `toml:"include"`
+				From    string `toml:"from"`
+			} `toml:"packages"`
+		} `toml:"poetry"`
+	} `toml:"tool"`
+}
+
+// Reads the pyproject.toml file in the project root, returning nil if there isn't one.
+func readPyprojectTOML(path string) (*pyprojectTOML, error) {
+	f, err := os.Open(filepath.Join(path, "pyproject.toml"))
+	if err != nil {
+		return nil, nil
+	}
+
+	defer f.Close()
+
+	var pyproject pyprojectTOML
+	if err := toml.NewDecoder(f).Decode(&pyproject); err != nil {
+		return nil, fmt.Errorf("Failed to decode pyproject.toml file")
+	}
+
+	return &pyproject, nil
+}
+
+func (p *pyprojectTOML) name() string {
+	if p.Project.Name != "" {
+		return p.Project.Name
+	}
+
+	return p.Tool.Poetry.Name
+}
+
+// Returns the console script to start the project with. When several scripts
+// are declared, the one named after the project or a common start script name
+// is preferred.
+func (p *pyprojectTOML) script() string {
+	scripts := []string{}
+	for name := range p.Project.Scripts {
+		scripts = append(scripts, name)
+	}
+
+	for name := range p.Tool.Poetry.Scripts {
+		scripts = append(scripts, name)
+	}
+
+	if len(scripts) == 0 {
+		return ""
+	}
+
+	for _, name := range []string{p.name(), "serve", "server", "start", "run", "web"} {
+		if slices.Contains(scripts, name) {
+			return name
+		}
+	}
+
+	slices.Sort(scripts)
+	return scripts[0]
+}
+
+// Returns the importable module of the project, supporting flat and src layouts,
+// or "" if its package directory doesn't exist. Packages outside the project
+// root, like a src layout, are only importable when the project is installed.
+func (p *pyprojectTOML) module(path string, installed bool) string {
+	for _, pkg := range p.Tool.Poetry.Packages {
+		if pkg.Include != "" && (pkg.From == "" || installed) {
+			return strings.ReplaceAll(pkg.Include, "/", ".")
+		}
+	}
+
+	name := p.name()
+	if name == "" {
+		return ""
+	}
+
+	module := strings.ReplaceAll(strings.ReplaceAll(strings.ToLower(name), "-", "_"), ".", "_")
+	dirs := []string{module}
+	if installed {
+		dirs = append(dirs, filepath.Join("src", module))
+	}
+	for _, dir := range dirs {
+		if stat, err := os.Stat(filepath.Join(path, dir)); err == nil && stat.IsDir() {
+			return module
+		}
+	}
+
+	return ""
+}
+
+type pythonApp struct {
+	// The directory the server should be started from, relative to the project root
+	Dir string
+	// The Python module containing the application, e.g. "mysite.wsgi"
+	Module string
+	// The variable holding the application object, e.g. "application"
+	Variable string
+	// Either "wsgi" or "asgi"
+	Interface string
+}
+
+// Finds the WSGI or ASGI application object that a production server should load.
+func findPythonApp(path string, managePy *string) *pythonApp {
+	if managePy != nil {
+		dir := filepath.Dir(*managePy)
+		entries, err := os.ReadDir(filepath.Join(path, dir))
+		if err == nil {
+			for _, entry := range entries {
+				if !entry.IsDir() {
+					continue
+				}
+
+				for _, iface := range []string{"wsgi", "asgi"} {
+					contents, err := os.ReadFile(filepath.Join(path, dir, entry.Name(), iface+".py"))
+					if err == nil && strings.Contains(string(contents), "get_"+iface+"_application") {
+						return &pythonApp{Dir: dir, Module: entry.Name() + "." + iface, Variable: "application", Interface: iface}
+					}
+				}
+			}
+		}
+	}
+
+	appFiles := []string{
+		"wsgi.py",
+		"asgi.py",
+		"main.py",
+		"app.py",
+		"application.py",
+		"server.py",
+		"app/__init__.py",
+		"app/main.py",
+		"app/app.py",
+		filepath.Join(filepath.Base(path), "__init__.py"),
+		filepath.Join(filepath.Base(path), "main.py"),
+		filepath.Join(filepath.Base(path), "app.py"),
+	}
+
+	for _, file := range appFiles {
+		f, err := os.Open(filepath.Join(path, file))
+		if err != nil {
+			continue
+		}
+
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			match := appObjectRe.FindStringSubmatch(scanner.Text())
+			if match == nil {
+				continue
+			}
+
+			f.Close()
+			module := strings.TrimSuffix(strings.TrimSuffix(file, ".py"), "/__init__")
+			iface := "asgi"
+			if match[2] == "Flask" {
+				iface = "wsgi"
+			}
+
+			return &pythonApp{Dir: ".", Module: strings.ReplaceAll(module, "/", "."), Variable: match[1], Interface: iface}
+		}
+
+		f.Close()
+	}
+
+	return nil
+}
+
+var appObjectRe = regexp.MustCompile(`^(\w+)\s*=\s*(Flask|FastAPI|Starlette|Quart|Litestar)\(`)
+
+// Returns a start command that serves the application with the first
+// production server found in the project's dependencies.
+func pythonServerCommand(app pythonApp, packages map[string]bool) string {
+	target := app.Module + ":" + app.Variable
+	// hypercorn and waitress can't change their working directory, so they
+	// are only used for applications in the project root
+	root := app.Dir == "."
+	chdir := func(flag string) string {
+		if root {
+			return ""
+		}
+		return flag + " " + app.Dir + " "
+	}
+
+	if app.Interface == "wsgi" {
+		switch {
+		case packages["gunicorn"]:
+			return "gunicorn " + chdir("--chdir") + "--bind 0.0.0.0:${PORT} " + target
+		case packages["granian"]:
+			return "granian --interface wsgi " + chdir("--working-dir") + "--host 0.0.0.0 --port ${PORT} --workers ${WEB_CONCURRENCY} " + target
+		case packages["waitress"] && root:
+			return "waitress-serve --listen=0.0.0.0:${PORT} " + target
+		}
+
+		return ""
+	}
+
+	switch {
+	case packages["gunicorn"] && packages["uvicorn"]:
+		return "gunicorn " + chdir("--chdir") + "--bind 0.0.0.0:${PORT} --worker-class uvicorn.workers.UvicornWorker " + target
+	case packages["uvicorn"]:
+		return "uvicorn " + chdir("--app-dir") + "--host 0.0.0.0 --port ${PORT} " + target
+	case packages["hypercorn"] && root:
+		return "hypercorn --bind 0.0.0.0:${PORT} --workers ${WEB_CONCURRENCY} " + target
+	case packages["granian"]:
+		return "granian --interface asgi " + chdir("--working-dir") + "--host 0.0.0.0 --port ${PORT} --workers ${WEB_CONCURRENCY} " + target
+	}
+
+	return ""
+}
+
+// Returns the commands for a background worker and scheduler when a task
+// queue is found in the project's dependencies.
+func findPythonWorker(path string, packages map[string]bool) (string, string) {
+	// A queue library may be installed without being used, e.g. as a transitive
+	// dependency, so fall through to the next one unless its app is found
+	if packages["celery"] {
+		if module, variable := findPythonModule(path, celeryAppRe); module != "" {
+			// Django projects conventionally define the app in <project>/celery.py,
+			// which celery finds when given the package name
+			app := module + ":" + variable
+			if strings.HasSuffix(module, ".celery") {
+				app = strings.TrimSuffix(module, ".celery")
+			}
+
+			return "celery -A " + app + " worker --loglevel=info", "celery -A " + app + " beat --loglevel=info"
+		}
+	}
+
+	if packages["dramatiq"] {
+		if module, _ := findPythonModule(path, dramatiqActorRe); module != "" {
+			return "dramatiq " + module, ""
+		}
+	}
+
+	if packages["arq"] {
+		if module, variable := findPythonModule(path, arqSettingsRe); module != "" {
+			return "arq " + module + "." + variable, ""
+		}
+	}
+
+	// rq reads its Redis connection from the environment, e.g. RQ_REDIS_URL
+	if packages["rq"] {
+		return "rq worker", ""
+	}
+
+	return "", ""
+}
+
+var celeryAppRe = regexp.MustCompile(`^(\w+)\s*=\s*Celery\(`)
+var dramatiqActorRe = regexp.MustCompile(`^@(dramatiq\.)?actor\b()`)
+var arqSettingsRe = regexp.MustCompile(`^class\s+(WorkerSettings)\b`)
+
+// Finds the first Python module in the project root or its immediate
+// subdirectories with a line matching re, returning the dotted module path
+// and the first submatch.
+func findPythonModule(path string, re *regexp.Regexp) (string, string) {
+	rootFiles, _ := filepath.Glob(filepath.Join(path, "*.py"))
+	packageFiles, _ := filepath.Glob(filepath.Join(path, "*", "*.py"))
+
+	for _, file := range append(rootFiles, packageFiles...) {
+		f, err := os.Open(file)
+		if err != nil {
+			continue
+		}
+
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			match := re.FindStringSubmatch(scanner.Text())
+			if match == nil {
+				continue
+			}
+
+			f.Close()
+			rel, _ := filepath.Rel(path, file)
+			module := strings.TrimSuffix(strings.TrimSuffix(filepath.ToSlash(rel), ".py"), "/__init__")
+			return strings.ReplaceAll(module, "/", "."), match[1]
+		}
+
+		f.Close()
+	}
+
+	return "", ""
+}
+
+// Returns the start command and container environment for Streamlit, Gradio,
+// Panel, Dash and Voila apps, which need to be told to bind to all interfaces
+// and the PORT.
+func findPythonDataApp(path string, packages map[string]bool) (string, string) {
+	entryFiles := []string{"streamlit_app.py", "app.py", "main.py", "Home.py", "dashboard.py"}
+
+	switch {
+	case packages["streamlit"]:
+		if file := findPythonEntry(path, entryFiles, "streamlit"); file != "" {
+			return "streamlit run " + file + " --server.port=${PORT} --server.address=0.0.0.0", streamlitInstructions
+		}
+	case packages["gradio"]:
+		if file := findPythonEntry(path, entryFiles, "gradio"); file != "" {
+			// Gradio's launch() reads the address and port from the environment
+			return "env GRADIO_SERVER_NAME=0.0.0.0 GRADIO_SERVER_PORT=${PORT} python " + file, gradioInstructions
+		}
+	case packages["panel"]:
+		if file := findPythonEntry(path, entryFiles, "panel"); file != "" {
+			return "panel serve " + file + " --address 0.0.0.0 --port ${PORT} --allow-websocket-origin=*", ""
+		}
+	case packages["dash"]:
+		file := findPythonEntry(path, entryFiles, "dash")
+		if file == "" {
+			break
+		}
+
+		// Dash apps expose their Flask server for WSGI servers
+		if module, variable := findPythonModule(path, dashServerRe); module != "" && packages["gunicorn"] {
+			return "gunicorn --bind 0.0.0.0:${PORT} " + module + ":" + variable, ""
+		}
+
+		return "python " + file, dashInstructions
+	case packages["voila"]:
+		notebooks, _ := filepath.Glob(filepath.Join(path, "*.ipynb"))
+		notebook := ""
+		if len(notebooks) == 1 {
+			notebook = filepath.Base(notebooks[0]) + " "
+		}
+
+		return "voila " + notebook + "--port=${PORT} --no-browser --Voila.ip=0.0.0.0", ""
+	}
+
+	return "", ""
+}
+
+var dashServerRe = regexp.MustCompile(`^(\w+)\s*=\s*\w+\.server\b`)
+
+// Returns the first of the given files that mentions the keyword, e.g. in an import.
+func findPythonEntry(path string, files []string, keyword string) string {
+	for _, file := range files {
+		contents, err := os.ReadFile(filepath.Join(path, file))
+		if err == nil && strings.Contains(string(contents), keyword) {
+			return file
+		}
+	}
+
+	return ""
+}
+
 type PythonPackageManager string
 
 const (
@@ -39,6 +39,76 @@ func TestPythonMatch(t *testing.T) {
 			path:     "../testdata/python-pyproject",
 			expected: true,
 		},
+		{
+			name:     "Python project with Flask",
+			path:     "../testdata/python-flask",
+			expected: true,
+		},
+		{
+			name:     "Python project with django and gunicorn",
+			path:     "../testdata/python-django-gunicorn",
+			expected: true,
+		},
+		{
+			name:     "Python project with Starlette",
+			path:     "../testdata/python-starlette",
+			expected: true,
+		},
+		{
+			name:     "Python project with native dependencies",
+			path:     "../testdata/python-native",
+			expected: true,
+		},
+		{
+			name:     "Python project with poetry scripts",
+			path:     "../testdata/python-poetry-scripts",
+			expected: true,
+		},
+		{
+			name:     "Python project with src layout",
+			path:     "../testdata/python-src-layout",
+			expected: true,
+		},
+		{
+			name:     "Python project with hatch",
+			path:     "../testdata/python-hatch",
+			expected: true,
+		},
+		{
+			name:     "Python project with Streamlit",
+			path:     "../testdata/python-streamlit",
+			expected: true,
+		},
+		{
+			name:     "Python project with Gradio",
+			path:     "../testdata/python-gradio",
+			expected: true,
+		},
+		{
+			name:     "Python project with Panel",
+			path:     "../testdata/python-panel",
+			expected: true,
+		},
+		{
+			name:     "Python project with Voila",
+			path:     "../testdata/python-voila",
+			expected: true,
+		},
+		{
+			name:     "Python project with Dash",
+			path:     "../testdata/python-dash",
+			expected: true,
+		},
+		{
+			name:     "Python project with Pipfile",
+			path:     "../testdata/python-pipfile",
+			expected: true,
+		},
+		{
+			name:     "Python project with uv",
+			path:     "../testdata/python-uv",
+			expected: true,
+		},
 		{
 			name:     "Not a Python project",
 			path:     "../testdata/deno",
@@ -69,6 +139,14 @@ func TestPythonGenerateDockerfile(t *testing.T) {
 				`ARG VERSION=3.12`,
 				`ARG INSTALL_CMD="pip install --no-cache -r requirements.txt"`,
 				`ARG START_CMD="python main.py"`,
+				regexp.MustCompile(`^ARG BUILD_PACKAGES=$`),
+				regexp.MustCompile(`^ARG RUNTIME_PACKAGES=$`),
+				regexp.MustCompile(`^ARG WORKER_CMD=$`),
+				`ARG PROCESS=web`,
+				`exec ${WORKER_CMD:?WORKER_CMD is not set}`,
+				`exec ${BEAT_CMD:?BEAT_CMD is not set}`,
+				`FROM ${BUILDER}:${VERSION} AS build`,
+				`COPY --from=build --chown=nonroot:nonroot /opt/venv /opt/venv`,
 			},
 		},
 		{
@@ -85,8 +163,10 @@ func TestPythonGenerateDockerfile(t *testing.T) {
 			path: "../testdata/python-django",
 			expected: []any{
 				`ARG VERSION=3.6.0`,
-				`ARG INSTALL_CMD="pip install pipenv && pipenv install --dev --system --deploy"`,
+				`ARG INSTALL_CMD="pipenv install --dev --system --deploy"`,
+				regexp.MustCompile(`^ARG BUILD_CMD=$`),
 				`ARG START_CMD="python manage.py runserver 0.0.0.0:${PORT}"`,
+				`ARG RELEASE_CMD="python manage.py migrate"`,
 			},
 		},
 		{
@@ -94,7 +174,7 @@ func TestPythonGenerateDockerfile(t *testing.T) {
 			path: "../testdata/python-pdm",
 			expected: []any{
 				`ARG VERSION=3.4.1`,
-				`ARG INSTALL_CMD="pip install pdm && pdm install --prod"`,
+				`ARG INSTALL_CMD="pdm install --prod"`,
 				`ARG START_CMD="python app.py"`,
 			},
 		},
@@ -103,8 +183,9 @@ func TestPythonGenerateDockerfile(t *testing.T) {
 			path: "../testdata/python-poetry",
 			expected: []any{
 				`ARG VERSION=3.8.5`,
-				`ARG INSTALL_CMD="pip install poetry && poetry install --no-dev --no-ansi --no-root"`,
+				`ARG INSTALL_CMD="poetry install --no-dev --no-ansi --no-root"`,
 				`ARG START_CMD="python app/main.py"`,
+				`RUN pip install --no-cache poetry`,
 			},
 		},
 		{
@@ -116,15 +197,184 @@ func TestPythonGenerateDockerfile(t *testing.T) {
 				`ARG START_CMD="python -m pyproject"`,
 			},
 		},
+		{
+			name: "Python project with pyproject and no package directory",
+			path: "../testdata/python-pyproject-no-package",
+			expected: []any{
+				`ARG INSTALL_CMD="pip install --upgrade build setuptools && pip install .`,
+				regexp.MustCompile(`^ARG START_CMD=$`),
+			},
+		},
+		{
+			name: "Python project with an invalid pyproject",
+			path: "../testdata/python-pyproject-invalid",
+			expected: []any{
+				`ARG VERSION=3.12`,
+				`ARG START_CMD="python main.py"`,
+			},
+		},
 		{
 			name: "Python project with FastAPI",
 			path: "../testdata/python-fastapi",
 			expected: []any{
 				`ARG VERSION=3.6.0`,
-				`ARG INSTALL_CMD="pip install pipenv && pipenv install --dev --system --deploy"`,
+				`ARG INSTALL_CMD="pipenv install --dev --system --deploy"`,
 				`ARG START_CMD="fastapi run main.py --port ${PORT}"`,
 			},
 		},
+		{
+			name: "Python project with Flask",
+			path: "../testdata/python-flask",
+			expected: []any{
+				`ARG START_CMD="gunicorn --bind 0.0.0.0:${PORT} app:app"`,
+				`ENV WEB_CONCURRENCY=${WEB_CONCURRENCY}`,
+				`ARG WORKER_CMD="rq worker"`,
+				regexp.MustCompile(`^ARG BEAT_CMD=$`),
+			},
+		},
+		{
+			name: "Python project with inline pyproject dependencies",
+			path: "../testdata/python-pyproject-inline",
+			expected: []any{
+				`ARG START_CMD="gunicorn --bind 0.0.0.0:${PORT} app:app"`,
+				`ARG BUILD_PACKAGES="libpq-dev"`,
+				`ARG RUNTIME_PACKAGES="libpq5"`,
+			},
+		},
+		{
+			name: "Python project with poetry.lock extras",
+			path: "../testdata/python-poetry-extras",
+			expected: []any{
+				`ARG START_CMD="gunicorn --bind 0.0.0.0:${PORT} app:app"`,
+				regexp.MustCompile(`^ARG WORKER_CMD=$`),
+				regexp.MustCompile(`^ARG BUILD_PACKAGES=$`),
+				regexp.MustCompile(`^ARG RUNTIME_PACKAGES=$`),
+			},
+		},
+		{
+			name: "Python project with an unused queue library",
+			path: "../testdata/python-dramatiq",
+			expected: []any{
+				`ARG WORKER_CMD="dramatiq tasks"`,
+				regexp.MustCompile(`^ARG BEAT_CMD=$`),
+			},
+		},
+		{
+			name: "Python project with django and gunicorn",
+			path: "../testdata/python-django-gunicorn",
+			expected: []any{
+				`ARG BUILD_CMD="python manage.py collectstatic --noinput"`,
+				`ARG START_CMD="gunicorn --bind 0.0.0.0:${PORT} mysite.wsgi:application"`,
+				`ARG RELEASE_CMD="python manage.py migrate"`,
+				`ARG WORKER_CMD="celery -A mysite worker --loglevel=info"`,
+				`ARG BEAT_CMD="celery -A mysite beat --loglevel=info"`,
+			},
+		},
+		{
+			name: "Python project with Starlette",
+			path: "../testdata/python-starlette",
+			expected: []any{
+				`ARG START_CMD="uvicorn --host 0.0.0.0 --port ${PORT} main:app"`,
+			},
+		},
+		{
+			name: "Python project with native dependencies",
+			path: "../testdata/python-native",
+			expected: []any{
+				`ARG BUILD_PACKAGES="libfreetype6-dev libjpeg62-turbo-dev libpq-dev libxml2-dev libxslt1-dev zlib1g-dev"`,
+				`ARG RUNTIME_PACKAGES="libfreetype6 libjpeg62-turbo libpq5 libxml2 libxslt1.1 zlib1g"`,
+				`ARG START_CMD="python main.py"`,
+			},
+		},
+		{
+			name: "Python project with poetry scripts",
+			path: "../testdata/python-poetry-scripts",
+			expected: []any{
+				`ARG VERSION=3.14`,
+				`ARG INSTALL_CMD="poetry install --no-dev --no-ansi"`,
+				`ARG START_CMD="serve"`,
+			},
+		},
+		{
+			name: "Python project with src layout",
+			path: "../testdata/python-src-layout",
+			expected: []any{
+				`ARG VERSION=3.11`,
+				`ARG INSTALL_CMD="pip install --upgrade build setuptools && pip install ."`,
+				`ARG START_CMD="python -m src_app"`,
+			},
+		},
+		{
+			name: "Python project with src layout that isn't installed",
+			path: "../testdata/python-src-layout-requirements",
+			expected: []any{
+				`ARG INSTALL_CMD="pip install --no-cache -r requirements.txt"`,
+				regexp.MustCompile(`^ARG START_CMD=$`),
+			},
+		},
+		{
+			name: "Python project with hatch",
+			path: "../testdata/python-hatch",
+			expected: []any{
+				`ARG VERSION=3.14`,
+				`ARG START_CMD="hatch-app"`,
+			},
+		},
+		{
+			name: "Python project with Streamlit",
+			path: "../testdata/python-streamlit",
+			expected: []any{
+				`ARG START_CMD="streamlit run streamlit_app.py --server.port=${PORT} --server.address=0.0.0.0"`,
+				`ENV STREAMLIT_SERVER_HEADLESS=true`,
+				`ENV STREAMLIT_BROWSER_GATHER_USAGE_STATS=false`,
+			},
+		},
+		{
+			name: "Python project with Gradio",
+			path: "../testdata/python-gradio",
+			expected: []any{
+				`ARG START_CMD="env GRADIO_SERVER_NAME=0.0.0.0 GRADIO_SERVER_PORT=${PORT} python app.py"`,
+				`ENV GRADIO_ANALYTICS_ENABLED=False`,
+			},
+		},
+		{
+			name: "Python project with Panel",
+			path: "../testdata/python-panel",
+			expected: []any{
+				`ARG START_CMD="panel serve app.py --address 0.0.0.0 --port ${PORT} --allow-websocket-origin=*"`,
+			},
+		},
+		{
+			name: "Python project with Voila",
+			path: "../testdata/python-voila",
+			expected: []any{
+				`ARG START_CMD="voila dashboard.ipynb --port=${PORT} --no-browser --Voila.ip=0.0.0.0"`,
+			},
+		},
+		{
+			name: "Python project with Dash",
+			path: "../testdata/python-dash",
+			expected: []any{
+				`ARG START_CMD="python app.py"`,
+				`ENV HOST=0.0.0.0`,
+			},
+		},
+		{
+			name: "Python project with Pipfile python_version",
+			path: "../testdata/python-pipfile",
+			expected: []any{
+				`ARG VERSION=3.11`,
+				`ARG START_CMD="python app.py"`,
+			},
+		},
+		{
+			name: "Python project with uv",
+			path: "../testdata/python-uv",
+			expected: []any{
+				`ARG VERSION=3.12`,
+				`ARG INSTALL_CMD="uv sync --python-preference=only-system --no-cache --no-dev"`,
+			},
+		},
 		{
 			name: "Not a Python project",
 			path: "../testdata/deno",
@@ -8,6 +8,8 @@ import (
 	"maps"
 	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
 	"strings"
 	"text/template"
 
@@ -43,11 +45,6 @@ func (d *Ruby) Match(path string) bool {
 }
 
 func (d *Ruby) GenerateDockerfile(path string, data ...map[string]string) ([]byte, error) {
-	tmpl, err := template.New("Dockerfile").Parse(rubyTemplate)
-	if err != nil {
-		return nil, fmt.Errorf("Failed to parse template")
-	}
-
 	// Parse version from go.mod
 	version, err := findRubyVersion(path, d.Log)
 	if err != nil {
@@ -68,43 +65,60 @@ func (d *Ruby) GenerateDockerfile(path string, data ...map[string]string) ([]byt
 		installCMD = installCMD + " && yarn --frozen-lockfile"
 	} else if _, err := os.Stat(filepath.Join(path, "bun.lockb")); err == nil {
 		packageManager = "bun"
-		installCMD = installCMD + " && bun install"
+		installCMD = installCMD + " && npm i -g bun && bun install"
 	}
 
 	if packageManager != "" {
 		d.Log.Info("Detected Node.js package manager: " + packageManager)
 	}
 
+	gems := findRubyGems(path)
 	isRails := isRailsProject(path)
+	if !isRails && gems["railties"] {
+		// Apps without ActiveRecord depend on railties instead of the rails gem
+		_, err := os.Stat(filepath.Join(path, "config/application.rb"))
+		isRails = err == nil
+	}
 	buildCMD := ""
 	startCMD := ""
+	releaseCMD := ""
 	if isRails {
 		d.Log.Info("Detected Rails project")
-		buildCMD = "bundle exec rake assets:precompile"
+		// Like the Rails generator, only precompile assets with an asset pipeline
+		if gems["sprockets"] || gems["propshaft"] {
+			// Rails 7.1+ skips credentials when SECRET_KEY_BASE_DUMMY is set, older
+			// versions only need SECRET_KEY_BASE to be present
+			buildCMD = "SECRET_KEY_BASE_DUMMY=1 SECRET_KEY_BASE=placeholder bundle exec rails assets:precompile"
+		}
 		startCMD = "bundle exec rails server -b 0.0.0.0 -p ${PORT}"
-	} else {
-		configFiles := []string{"config.ru", "config/environment.rb", "Rakefile"}
-
-		for _, fn := range configFiles {
-			_, err := os.Stat(filepath.Join(path, fn))
-			if err != nil {
-				continue
-			}
+		if gems["activerecord"] {
+			releaseCMD = "bundle exec rails db:prepare"
+		}
+	} else if isHanamiProject(path, gems) {
+		d.Log.Info("Detected Hanami project")
+		if gems["hanami-assets"] {
+			buildCMD = "bundle exec hanami assets compile"
+		}
+		startCMD = rackServerCommand(path, gems)
+	} else if _, err := os.Stat(filepath.Join(path, "config.ru")); err == nil {
+		d.Log.Info("Detected Rack project")
+		startCMD = rackServerCommand(path, gems)
+	} else if app := findSinatraApp(path); app != "" {
+		d.Log.Info("Detected Sinatra project")
+		startCMD = "bundle exec ruby " + app + " -o 0.0.0.0 -p ${PORT}"
+	} else if _, err := os.Stat(filepath.Join(path, "config/environment.rb")); err == nil {
+		d.Log.Info("Detected Rails project")
+		startCMD = "bundle exec ruby script/server"
+	} else if _, err := os.Stat(filepath.Join(path, "Rakefile")); err == nil {
+		// The default rake task is usually the test suite, not a server
+		d.Log.Warn("Detected a Rakefile, but no web server. Set START_CMD to start the project")
+	}
 
-			switch fn {
-			case "config.ru":
-				d.Log.Info("Detected Rack project")
-				startCMD = "bundle exec rackup config.ru -p ${PORT}"
-			case "config/environment.rb":
-				d.Log.Info("Detected Rails project")
-				startCMD = "bundle exec ruby script/server"
-			case "Rakefile":
-				d.Log.Info("Detected Rake project")
-				startCMD = "bundle exec rake"
-			}
+	workerCMD := findRubyWorker(path, gems)
 
-			break
-		}
+	buildPackages, runtimePackages := findSystemPackages(rubySystemPackageMap, gems)
+	if len(buildPackages) > 0 || len(runtimePackages) > 0 {
+		d.Log.Info("Detected native gems that require system libraries")
 	}
 
 	d.Log.Info(
@@ -114,17 +128,48 @@ func (d *Ruby) GenerateDockerfile(path string, data ...map[string]string) ([]byt
   Install command      : %s
   Build command        : %s
   Start command        : %s
+  Release command      : %s
+  Worker command       : %s
+  Build packages       : %s
+  Runtime packages     : %s
 
   Docker build arguments can supersede these defaults if provided.
-  See https://flexstack.com/docs/languages-and-frameworks/autogenerate-dockerfile`, *version, packageManager, installCMD, buildCMD, startCMD),
+  See https://flexstack.com/docs/languages-and-frameworks/autogenerate-dockerfile`, *version, packageManager, installCMD, buildCMD, startCMD, releaseCMD, workerCMD, strings.Join(buildPackages, " "), strings.Join(runtimePackages, " ")),
 	)
 
+	tpl := rubyTemplate
+	nodeStage := ""
+	nodeInstructions := ""
+	bootsnapInstructions := ""
+	if isRails {
+		tpl = rubyRailsTemplate
+		if packageManager != "" {
+			nodeStage = rubyNodeStage
+			nodeInstructions = rubyNodeInstructions
+		}
+		if gems["bootsnap"] {
+			bootsnapInstructions = rubyBootsnapInstructions
+		}
+	}
+
+	tmpl, err := template.New("Dockerfile").Parse(tpl)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse template")
+	}
+
 	var buf bytes.Buffer
 	templateData := map[string]string{
-		"Version":    *version,
-		"InstallCMD": safeCommand(installCMD),
-		"BuildCMD":   safeCommand(buildCMD),
-		"StartCMD":   safeCommand(startCMD),
+		"Version":              *version,
+		"InstallCMD":           safeCommand(installCMD),
+		"BuildCMD":             safeCommand(buildCMD),
+		"StartCMD":             safeCommand(startCMD),
+		"ReleaseCMD":           safeCommand(releaseCMD),
+		"WorkerCMD":            safeCommand(workerCMD),
+		"BuildPackages":        safeCommand(strings.Join(buildPackages, " ")),
+		"RuntimePackages":      safeCommand(strings.Join(runtimePackages, " ")),
+		"NodeStage":            nodeStage,
+		"NodeInstructions":     nodeInstructions,
+		"BootsnapInstructions": bootsnapInstructions,
 	}
 	if len(data) > 0 {
 		maps.Copy(templateData, data[0])
@@ -139,38 +184,138 @@ func (d *Ruby) GenerateDockerfile(path string, data ...map[string]string) ([]byt
 var rubyTemplate = strings.TrimSpace(`
 ARG VERSION={{.Version}}
 ARG BUILDER=docker.io/library/ruby
-FROM ${BUILDER}:${VERSION}-slim
+FROM ${BUILDER}:${VERSION}-slim AS build
 WORKDIR /app
-RUN apt-get update && apt-get install -y --no-install-recommends wget ca-certificates && apt-get clean && rm -f /var/lib/apt/lists/*_*
-RUN update-ca-certificates 2>/dev/null || true
-RUN addgroup --system nonroot && adduser --system --ingroup nonroot nonroot
+# Compilers and headers for native gems stay in the build stage
+ARG BUILD_PACKAGES={{.BuildPackages}}
+RUN apt-get update && apt-get install -y --no-install-recommends build-essential git pkg-config ${BUILD_PACKAGES} && apt-get clean && rm -f /var/lib/apt/lists/*_*
 
 ARG INSTALL_CMD={{.InstallCMD}}
 ARG BUILD_CMD={{.BuildCMD}}
 ENV NODE_ENV=production
+ENV RACK_ENV=production
+
+COPY . .
+
+RUN {{.InstallMounts}}if [ ! -z "${INSTALL_CMD}" ]; then sh -c "$INSTALL_CMD";  fi
+RUN {{.BuildMounts}}if [ ! -z "${BUILD_CMD}" ]; then sh -c "$BUILD_CMD"; fi
 
+FROM ${BUILDER}:${VERSION}-slim AS runtime
+WORKDIR /app
+# Shared libraries needed by native gems at runtime
+ARG RUNTIME_PACKAGES={{.RuntimePackages}}
+RUN apt-get update && apt-get install -y --no-install-recommends wget ca-certificates ${RUNTIME_PACKAGES} && apt-get clean && rm -f /var/lib/apt/lists/*_*
+RUN update-ca-certificates 2>/dev/null || true
+RUN addgroup --system nonroot && adduser --system --ingroup nonroot nonroot
 RUN chown -R nonroot:nonroot /app
-COPY --chown=nonroot:nonroot . .
+
+COPY --from=build /usr/local/bundle /usr/local/bundle
+COPY --from=build --chown=nonroot:nonroot /app /app
+ENV NODE_ENV=production
+ENV RACK_ENV=production
+
+ENV PORT=8080
+EXPOSE ${PORT}
+USER nonroot:nonroot
+
+ARG START_CMD={{.StartCMD}}
+ENV START_CMD=${START_CMD}
+RUN if [ -z "${START_CMD}" ]; then echo "Unable to detect a container start command" && exit 1; fi
+
+# The same image can run a background job processor by setting PROCESS to "worker"
+ARG WORKER_CMD={{.WorkerCMD}}
+ENV WORKER_CMD=${WORKER_CMD}
+ARG PROCESS=web
+ENV PROCESS=${PROCESS}
+CMD if [ "${PROCESS}" = "worker" ]; then exec ${WORKER_CMD:?WORKER_CMD is not set}; else exec ${START_CMD}; fi
+`)
+
+// Modelled on the Dockerfile generated by Rails 7.1
+var rubyRailsTemplate = strings.TrimSpace(`
+ARG VERSION={{.Version}}
+ARG BUILDER=docker.io/library/ruby
+ARG NODE_VERSION=22
+{{.NodeStage}}
+FROM ${BUILDER}:${VERSION}-slim AS base
+WORKDIR /app
+ENV RAILS_ENV=production \
+    BUNDLE_DEPLOYMENT=1 \
+    BUNDLE_PATH=/usr/local/bundle \
+    BUNDLE_WITHOUT=development:test \
+    RAILS_LOG_TO_STDOUT=1 \
+    RAILS_SERVE_STATIC_FILES=1
+
+FROM base AS build
+# Compilers and headers for native gems stay in the build stage
+ARG BUILD_PACKAGES={{.BuildPackages}}
+RUN apt-get update && apt-get install -y --no-install-recommends build-essential git pkg-config ${BUILD_PACKAGES} && apt-get clean && rm -f /var/lib/apt/lists/*_*
+{{.NodeInstructions}}
+ARG INSTALL_CMD={{.InstallCMD}}
+ARG BUILD_CMD={{.BuildCMD}}
+
+COPY . .
 
 RUN {{.InstallMounts}}if [ ! -z "${INSTALL_CMD}" ]; then sh -c "$INSTALL_CMD";  fi
+RUN rm -rf ~/.bundle/ "${BUNDLE_PATH}"/ruby/*/cache "${BUNDLE_PATH}"/ruby/*/bundler/gems/*/.git
+{{.BootsnapInstructions}}
 RUN {{.BuildMounts}}if [ ! -z "${BUILD_CMD}" ]; then sh -c "$BUILD_CMD"; fi
 
+FROM base AS runtime
+# Shared libraries needed by native gems at runtime
+ARG RUNTIME_PACKAGES={{.RuntimePackages}}
+RUN apt-get update && apt-get install -y --no-install-recommends wget ca-certificates libjemalloc2 ${RUNTIME_PACKAGES} && apt-get clean && rm -f /var/lib/apt/lists/*_*
+RUN update-ca-certificates 2>/dev/null || true
+RUN addgroup --system nonroot && adduser --system --ingroup nonroot nonroot
+RUN chown -R nonroot:nonroot /app
+
+COPY --from=build /usr/local/bundle /usr/local/bundle
+COPY --from=build --chown=nonroot:nonroot /app /app
+ENV LD_PRELOAD=libjemalloc.so.2 \
+    MALLOC_CONF=dirty_decay_ms:1000,narenas:2,background_thread:true
+
 ENV PORT=8080
 EXPOSE ${PORT}
 USER nonroot:nonroot
 
+ARG RELEASE_CMD={{.ReleaseCMD}}
+ENV RELEASE_CMD=${RELEASE_CMD}
 ARG START_CMD={{.StartCMD}}
 ENV START_CMD=${START_CMD}
 RUN if [ -z "${START_CMD}" ]; then echo "Unable to detect a container start command" && exit 1; fi
-CMD ${START_CMD}
+
+# The same image can run a background job processor by setting PROCESS to "worker"
+ARG WORKER_CMD={{.WorkerCMD}}
+ENV WORKER_CMD=${WORKER_CMD}
+ARG PROCESS=web
+ENV PROCESS=${PROCESS}
+# Prepare the database before the server boots, like bin/docker-entrypoint
+CMD if [ "${PROCESS}" = "worker" ]; then exec ${WORKER_CMD:?WORKER_CMD is not set}; fi; if [ ! -z "${RELEASE_CMD}" ]; then sh -c "$RELEASE_CMD" || exit 1; fi; exec ${START_CMD}
 `)
 
+var rubyNodeStage = `
+FROM docker.io/library/node:${NODE_VERSION}-slim AS node
+`
+
+var rubyNodeInstructions = `
+# Node.js for jsbundling-rails and cssbundling-rails
+COPY --from=node /usr/local/bin /usr/local/bin
+COPY --from=node /usr/local/lib/node_modules /usr/local/lib/node_modules
+# The yarn binary links into /opt/yarn-v*
+COPY --from=node /opt /opt
+`
+
+var rubyBootsnapInstructions = `
+# Precompile bootsnap caches so the app boots faster
+RUN bundle exec bootsnap precompile --gemfile app/ lib/
+`
+
 func findRubyVersion(path string, log *slog.Logger) (*string, error) {
 	version := ""
 	versionFiles := []string{
 		".tool-versions",
 		".ruby-version",
 		".mise.toml",
+		"Gemfile.lock",
 		"Gemfile",
 	}
 
@@ -216,6 +361,31 @@ func findRubyVersion(path string, log *slog.Logger) (*string, error) {
 					return nil, fmt.Errorf("Failed to read go.mod file")
 				}
 
+			case "Gemfile.lock":
+				// RUBY VERSION
+				//    ruby 3.2.2p53
+				scanner := bufio.NewScanner(f)
+				inRubyVersion := false
+				for scanner.Scan() {
+					line := scanner.Text()
+					if line == "RUBY VERSION" {
+						inRubyVersion = true
+						continue
+					}
+
+					if inRubyVersion {
+						if match := lockRubyVersionRe.FindStringSubmatch(line); match != nil {
+							version = match[1]
+							log.Info("Detected Ruby version from Gemfile.lock: " + version)
+						}
+						break
+					}
+				}
+
+				if err := scanner.Err(); err != nil {
+					return nil, fmt.Errorf("Failed to read Gemfile.lock")
+				}
+
 			case "Gemfile":
 				scanner := bufio.NewScanner(f)
 				for scanner.Scan() {
@@ -225,7 +395,19 @@ func findRubyVersion(path string, log *slog.Logger) (*string, error) {
 						if len(v) < 2 {
 							v = strings.Split(line, "\"")
 						}
+						if len(v) < 2 {
+							continue
+						}
 						ruby := v[1]
+
+						// ruby file: ".ruby-version" reads the version from another file
+						if strings.Contains(v[0], "file:") {
+							contents, err := os.ReadFile(filepath.Join(path, ruby))
+							if err != nil {
+								continue
+							}
+							ruby = strings.TrimPrefix(strings.TrimSpace(string(contents)), "ruby-")
+						}
 						if gteVersionRe.MatchString(ruby) {
 							version = gteVersionRe.FindStringSubmatch(ruby)[1]
 						} else if rangeVersionRe.MatchString(ruby) {
@@ -301,3 +483,134 @@ func isRailsProject(path string) bool {
 
 	return false
 }
+
+func isHanamiProject(path string, gems map[string]bool) bool {
+	if !gems["hanami"] {
+		return false
+	}
+
+	_, err := os.Stat(filepath.Join(path, "config/app.rb"))
+	return err == nil
+}
+
+// Returns the command to serve config.ru with the app server in the bundle,
+// deferring to its config file when there is one.
+func rackServerCommand(path string, gems map[string]bool) string {
+	switch {
+	case gems["puma"]:
+		contents, err := os.ReadFile(filepath.Join(path, "config/puma.rb"))
+		if err != nil {
+			return "bundle exec puma -p ${PORT}"
+		}
+		if pumaBindRe.Match(contents) {
+			return "bundle exec puma -C config/puma.rb"
+		}
+		return "bundle exec puma -C config/puma.rb -p ${PORT}"
+	case gems["falcon"]:
+		return "bundle exec falcon serve --bind http://0.0.0.0:${PORT}"
+	case gems["unicorn"]:
+		if _, err := os.Stat(filepath.Join(path, "config/unicorn.rb")); err == nil {
+			return "bundle exec unicorn -c config/unicorn.rb -p ${PORT}"
+		}
+		return "bundle exec unicorn -p ${PORT}"
+	}
+
+	return "bundle exec rackup config.ru -o 0.0.0.0 -p ${PORT}"
+}
+
+// Matches a port or bind line that reads PORT, e.g. port ENV.fetch("PORT", 3000).
+// Other variables, like Hanami's HANAMI_PORT, leave -p ${PORT} in the command.
+var pumaBindRe = regexp.MustCompile(`(?m)^\s*(port|bind)\b.*ENV(\.fetch\(\s*|\[\s*)["']PORT["']`)
+
+// Returns the file of a classic Sinatra app, which serves itself when run
+// with ruby.
+func findSinatraApp(path string) string {
+	files, _ := filepath.Glob(filepath.Join(path, "*.rb"))
+	slices.SortStableFunc(files, func(a, b string) int {
+		if filepath.Base(a) == "app.rb" {
+			return -1
+		}
+		if filepath.Base(b) == "app.rb" {
+			return 1
+		}
+		return 0
+	})
+
+	for _, fp := range files {
+		contents, err := os.ReadFile(fp)
+		if err != nil {
+			continue
+		}
+		if sinatraRequireRe.Match(contents) {
+			return filepath.Base(fp)
+		}
+	}
+
+	return ""
+}
+
+var sinatraRequireRe = regexp.MustCompile(`(?m)^\s*require\s+["']sinatra["']`)
+
+// Returns the command for a background job processor when one is found in
+// the bundle.
+func findRubyWorker(path string, gems map[string]bool) string {
+	switch {
+	case gems["sidekiq"]:
+		if _, err := os.Stat(filepath.Join(path, "config/sidekiq.yml")); err == nil {
+			return "bundle exec sidekiq -C config/sidekiq.yml"
+		}
+		return "bundle exec sidekiq"
+	case gems["good_job"]:
+		return "bundle exec good_job start"
+	}
+
+	return ""
+}
+
+var lockRubyVersionRe = regexp.MustCompile(`^\s+ruby\s+([\d.]+)`)
+
+// Returns the names of the gems in Gemfile.lock, or those declared in the
+// Gemfile when there is no lockfile.
+func findRubyGems(path string) map[string]bool {
+	gems := map[string]bool{}
+	if f, err := os.Open(filepath.Join(path, "Gemfile.lock")); err == nil {
+		defer f.Close()
+
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			if match := lockGemRe.FindStringSubmatch(scanner.Text()); match != nil {
+				gems[match[1]] = true
+			}
+		}
+
+		return gems
+	}
+
+	if f, err := os.Open(filepath.Join(path, "Gemfile")); err == nil {
+		defer f.Close()
+
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			if match := gemfileGemRe.FindStringSubmatch(scanner.Text()); match != nil {
+				gems[match[1]] = true
+			}
+		}
+	}
+
+	return gems
+}
+
+var lockGemRe = regexp.MustCompile(`^    ([A-Za-z0-9_.-]+) \(`)
+var gemfileGemRe = regexp.MustCompile(`^\s*gem\s+["']([A-Za-z0-9_.-]+)["']`)
+
+// Maps native gems to the Debian packages they need to compile and run.
+var rubySystemPackageMap = map[string]systemPackages{
+	"pg":          {Build: []string{"libpq-dev"}, Runtime: []string{"libpq5"}},
+	"mysql2":      {Build: []string{"default-libmysqlclient-dev"}, Runtime: []string{"libmariadb3"}},
+	"sqlite3":     {Build: []string{"libsqlite3-dev"}, Runtime: []string{"libsqlite3-0"}},
+	"nokogiri":    {Build: []string{"libxml2-dev", "libxslt1-dev"}, Runtime: []string{"libxml2", "libxslt1.1"}},
+	"psych":       {Build: []string{"libyaml-dev"}, Runtime: []string{"libyaml-0-2"}},
+	"ffi":         {Build: []string{"libffi-dev"}, Runtime: []string{"libffi8"}},
+	"rmagick":     {Build: []string{"libmagickwand-dev"}, Runtime: []string{"imagemagick"}},
+	"mini_magick": {Runtime: []string{"imagemagick"}},
+}
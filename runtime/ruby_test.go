@@ -34,6 +34,16 @@ func TestRubyMatch(t *testing.T) {
 			path:     "../testdata/ruby-rails",
 			expected: true,
 		},
+		{
+			name:     "Ruby project with native gems",
+			path:     "../testdata/ruby-native",
+			expected: true,
+		},
+		{
+			name:     "Ruby project with a Rakefile",
+			path:     "../testdata/ruby-rakefile",
+			expected: true,
+		},
 		{
 			name:     "Not a Ruby project",
 			path:     "../testdata/deno",
@@ -95,7 +105,7 @@ func TestRubyGenerateDockerfile(t *testing.T) {
 				`ARG VERSION=2.3.0`,
 				regexp.MustCompile(`^ARG INSTALL_CMD="bundle install"$`),
 				regexp.MustCompile(`^ARG BUILD_CMD=$`),
-				`ARG START_CMD="bundle exec rackup config.ru -p ${PORT}"`,
+				`ARG START_CMD="bundle exec rackup config.ru -o 0.0.0.0 -p ${PORT}"`,
 			},
 		},
 		{
@@ -118,8 +128,72 @@ func TestRubyGenerateDockerfile(t *testing.T) {
 			expected: []any{
 				`ARG VERSION=3.1`,
 				`ARG INSTALL_CMD="bundle install && corepack enable pnpm && pnpm i --frozen-lockfile"`,
-				`ARG BUILD_CMD="bundle exec rake assets:precompile"`,
+				`ARG BUILD_CMD="SECRET_KEY_BASE_DUMMY=1 SECRET_KEY_BASE=placeholder bundle exec rails assets:precompile"`,
 				`ARG START_CMD="bundle exec rails server -b 0.0.0.0 -p ${PORT}`,
+				`ARG RELEASE_CMD="bundle exec rails db:prepare"`,
+				`BUNDLE_WITHOUT=development:test`,
+				`BUNDLE_DEPLOYMENT=1`,
+				`ENV RAILS_ENV=production`,
+				`RUN bundle exec bootsnap precompile --gemfile app/ lib/`,
+				`then exec ${WORKER_CMD:?WORKER_CMD is not set}; fi;`,
+				`ENV LD_PRELOAD=libjemalloc.so.2`,
+				`ARG NODE_VERSION=22`,
+				`COPY --from=node /usr/local/bin /usr/local/bin`,
+				`COPY --from=node /opt /opt`,
+			},
+		},
+		{
+			name: "Ruby project with rails and no database or asset pipeline",
+			path: "../testdata/ruby-rails-api",
+			expected: []any{
+				regexp.MustCompile(`^ARG BUILD_CMD=$`),
+				`ARG START_CMD="bundle exec rails server -b 0.0.0.0 -p ${PORT}`,
+				regexp.MustCompile(`^ARG RELEASE_CMD=$`),
+				`ENV RAILS_ENV=production`,
+			},
+		},
+		{
+			name: "Ruby project with puma",
+			path: "../testdata/ruby-puma",
+			expected: []any{
+				`ARG START_CMD="bundle exec puma -C config/puma.rb"`,
+				`ARG WORKER_CMD="bundle exec sidekiq -C config/sidekiq.yml"`,
+				`ENV RACK_ENV=production`,
+				`CMD if [ "${PROCESS}" = "worker" ]; then exec ${WORKER_CMD:?WORKER_CMD is not set}; else exec ${START_CMD}; fi`,
+			},
+		},
+		{
+			name: "Ruby project with hanami",
+			path: "../testdata/ruby-hanami",
+			expected: []any{
+				`ARG BUILD_CMD="bundle exec hanami assets compile"`,
+				`ARG START_CMD="bundle exec puma -C config/puma.rb -p ${PORT}"`,
+				`ARG WORKER_CMD="bundle exec good_job start"`,
+			},
+		},
+		{
+			name: "Ruby project with sinatra",
+			path: "../testdata/ruby-sinatra",
+			expected: []any{
+				`ARG START_CMD="bundle exec ruby app.rb -o 0.0.0.0 -p ${PORT}"`,
+				regexp.MustCompile(`^ARG WORKER_CMD=$`),
+			},
+		},
+		{
+			name: "Ruby project with a Rakefile",
+			path: "../testdata/ruby-rakefile",
+			expected: []any{
+				regexp.MustCompile(`^ARG START_CMD=$`),
+			},
+		},
+		{
+			name: "Ruby project with native gems",
+			path: "../testdata/ruby-native",
+			expected: []any{
+				`ARG VERSION=3.3.4`,
+				`ARG BUILD_PACKAGES="libpq-dev libxml2-dev libxslt1-dev libyaml-dev"`,
+				`ARG RUNTIME_PACKAGES="libpq5 libxml2 libxslt1.1 libyaml-0-2"`,
+				`COPY --from=build /usr/local/bundle /usr/local/bundle`,
 			},
 		},
 		{
@@ -1,6 +1,7 @@
 package runtime
 
 import (
+	"bufio"
 	"bytes"
 	"fmt"
 	"log/slog"
@@ -87,13 +88,49 @@ func (d *Rust) GenerateDockerfile(path string, data ...map[string]string) ([]byt
 		}
 	}
 
+	toolchain, err := findRustToolchain(path, d.Log)
+	if err != nil {
+		return nil, err
+	}
+
+	d.Log.Info(
+		fmt.Sprintf(`Detected defaults 
+  Rust version : %s
+  Components   : %s
+  Targets      : %s
+  Binary name  : %s
+
+  Docker build arguments can supersede these defaults if provided.
+  See https://flexstack.com/docs/languages-and-frameworks/autogenerate-dockerfile`, toolchain.Channel, strings.Join(toolchain.Components, ", "), strings.Join(toolchain.Targets, ", "), binName),
+	)
+
+	libcInstructions := ""
+	glibcCrate, err := findGlibcCrate(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if glibcCrate != "" {
+		d.Log.Info("Detected " + glibcCrate + " in Cargo.lock. Static musl builds are disabled.")
+		libcInstructions = fmt.Sprintf(`RUN if [ "${LIBC}" = "musl" ]; then echo "Static musl builds are not supported: %s requires glibc" && exit 1; fi`, glibcCrate)
+	}
+
 	var buf bytes.Buffer
 	templateData := map[string]string{
-		"BinName": binName,
+		"Version":          toolchain.Channel,
+		"Components":       strings.Join(toolchain.Components, ","),
+		"Targets":          strings.Join(toolchain.Targets, ","),
+		"BinName":          binName,
+		"Libc":             "gnu",
+		"LibcInstructions": libcInstructions,
 	}
 	if len(data) > 0 {
 		maps.Copy(templateData, data[0])
 	}
+	if templateData["Libc"] == "musl" && glibcCrate != "" {
+		d.Log.Warn("Refusing to build a static musl binary because " + glibcCrate + " requires glibc")
+		templateData["Libc"] = "gnu"
+	}
 	if err := tmpl.Option("missingkey=zero").Execute(&buf, templateData); err != nil {
 		return nil, fmt.Errorf("Failed to execute template")
 	}
@@ -102,18 +139,42 @@ func (d *Rust) GenerateDockerfile(path string, data ...map[string]string) ([]byt
 }
 
 var rustlangTemplate = strings.TrimSpace(`
+ARG VERSION={{.Version}}
+# Set LIBC=musl to build a static binary that runs on a scratch image
+ARG LIBC={{.Libc}}
+# The runtime image for static builds, e.g. gcr.io/distroless/static-debian12
+ARG STATIC_IMAGE=scratch
 ARG BUILDPLATFORM=linux
 ARG BUILDER=docker.io/messense/cargo-zigbuild
-FROM --platform=${BUILDPLATFORM} ${BUILDER}:latest AS build
+FROM --platform=${BUILDPLATFORM} ${BUILDER}:latest AS base
+# cargo-chef computes a recipe of the project's dependencies so they can be
+# compiled in their own layer and reused until Cargo.toml or Cargo.lock changes
+RUN cargo install cargo-chef --locked
 WORKDIR /app
+
+FROM base AS planner
 COPY . .
+RUN cargo chef prepare --recipe-path recipe.json
+
+FROM base AS build
+ARG VERSION
+ARG RUST_COMPONENTS={{.Components}}
+ARG RUST_TARGETS={{.Targets}}
+RUN rustup toolchain install "${VERSION}" --profile minimal ${RUST_COMPONENTS:+--component ${RUST_COMPONENTS}} ${RUST_TARGETS:+--target ${RUST_TARGETS}} && rustup default "${VERSION}"
 
 ARG TARGETOS=linux
 ARG TARGETARCH=amd64
-RUN if [ "${TARGETARCH}" = "amd64" ]; then rustup target add x86_64-unknown-linux-gnu; else rustup target add aarch64-unknown-linux-gnu; fi
-RUN if [ "${TARGETARCH}" = "amd64" ]; then cargo zigbuild --release --target x86_64-unknown-linux-gnu; else cargo zigbuild --release --target aarch64-unknown-linux-gnu; fi
+ARG LIBC
+{{.LibcInstructions}}
+RUN if [ "${TARGETARCH}" = "amd64" ]; then rustup target add x86_64-unknown-linux-${LIBC}; else rustup target add aarch64-unknown-linux-${LIBC}; fi
 
-FROM debian:stable-slim AS runtime
+COPY --from=planner /app/recipe.json recipe.json
+RUN {{.InstallMounts}}if [ "${TARGETARCH}" = "amd64" ]; then cargo chef cook --release --zigbuild --target x86_64-unknown-linux-${LIBC} --recipe-path recipe.json; else cargo chef cook --release --zigbuild --target aarch64-unknown-linux-${LIBC} --recipe-path recipe.json; fi
+
+COPY . .
+RUN {{.BuildMounts}}if [ "${TARGETARCH}" = "amd64" ]; then cargo zigbuild --release --target x86_64-unknown-linux-${LIBC}; else cargo zigbuild --release --target aarch64-unknown-linux-${LIBC}; fi
+
+FROM debian:stable-slim AS runtime-gnu
 WORKDIR /app
 
 RUN apt-get update && apt-get install -y --no-install-recommends wget ca-certificates && apt-get clean && rm -f /var/lib/apt/lists/*_*
@@ -130,4 +191,235 @@ USER nonroot:nonroot
 ENV PORT=8080
 EXPOSE ${PORT}
 CMD ["/app/app"]
+
+FROM ${STATIC_IMAGE} AS runtime-musl
+WORKDIR /app
+COPY --from=build /etc/ssl/certs/ca-certificates.crt /etc/ssl/certs/ca-certificates.crt
+
+ARG BIN_NAME={{.BinName}}
+ENV BIN_NAME=${BIN_NAME}
+COPY --chown=65532:65532 --from=build /app/target/*/release/${BIN_NAME} ./app
+
+USER 65532:65532
+
+ENV PORT=8080
+EXPOSE ${PORT}
+CMD ["/app/app"]
+
+FROM runtime-${LIBC} AS runtime
 `)
+
+type rustToolchain struct {
+	Channel    string
+	Components []string
+	Targets    []string
+}
+
+func findRustToolchain(path string, log *slog.Logger) (*rustToolchain, error) {
+	toolchain := rustToolchain{}
+	versionFiles := []string{
+		"rust-toolchain.toml",
+		"rust-toolchain",
+		".tool-versions",
+		".mise.toml",
+		"Cargo.toml",
+	}
+
+	for _, file := range versionFiles {
+		fp := filepath.Join(path, file)
+		_, err := os.Stat(fp)
+
+		if err == nil {
+			f, err := os.Open(fp)
+			if err != nil {
+				continue
+			}
+
+			defer f.Close()
+			switch file {
+			case "rust-toolchain.toml", "rust-toolchain":
+				contents, err := os.ReadFile(fp)
+				if err != nil {
+					return nil, fmt.Errorf("Failed to read " + file + " file")
+				}
+
+				// The legacy rust-toolchain file may contain only the channel name
+				if file == "rust-toolchain" && !strings.Contains(string(contents), "[toolchain]") {
+					toolchain.Channel = strings.TrimSpace(string(contents))
+					log.Info("Detected Rust version in rust-toolchain: " + toolchain.Channel)
+					break
+				}
+
+				var toolchainTOML struct {
+					Toolchain struct {
+						Channel    string   `toml:"channel"`
+						Components []string `toml:"components"`
+						Targets    []string `toml:"targets"`
+					} `toml:"toolchain"`
+				}
+				if err := toml.Unmarshal(contents, &toolchainTOML); err != nil {
+					return nil, fmt.Errorf("Failed to decode " + file + " file")
+				}
+
+				toolchain.Channel = toolchainTOML.Toolchain.Channel
+				toolchain.Components = toolchainTOML.Toolchain.Components
+				toolchain.Targets = toolchainTOML.Toolchain.Targets
+				if toolchain.Channel != "" {
+					log.Info("Detected Rust version in " + file + ": " + toolchain.Channel)
+				}
+
+			case ".tool-versions":
+				scanner := bufio.NewScanner(f)
+				for scanner.Scan() {
+					line := scanner.Text()
+					if strings.HasPrefix(line, "rust ") {
+						toolchain.Channel = strings.Split(line, " ")[1]
+						log.Info("Detected Rust version in .tool-versions: " + toolchain.Channel)
+						break
+					}
+				}
+
+				if err := scanner.Err(); err != nil {
+					return nil, fmt.Errorf("Failed to read .tool-versions file")
+				}
+
+			case ".mise.toml":
+				var mise MiseToml
+				if err := toml.NewDecoder(f).Decode(&mise); err != nil {
+					return nil, fmt.Errorf("Failed to decode .mise.toml file")
+				}
+				rustVersion, ok := mise.Tools["rust"].(string)
+				if !ok {
+					versions, ok := mise.Tools["rust"].([]string)
+					if ok {
+						rustVersion = versions[0]
+					}
+				}
+				if rustVersion != "" {
+					toolchain.Channel = rustVersion
+					log.Info("Detected Rust version in .mise.toml: " + toolchain.Channel)
+					break
+				}
+
+			case "Cargo.toml":
+				// Fall back to the minimum supported Rust version (MSRV)
+				var cargoTOML cargoRustVersion
+				if err := toml.NewDecoder(f).Decode(&cargoTOML); err != nil {
+					log.Warn("Failed to decode rust-version in Cargo.toml")
+					break
+				}
+
+				switch rustVersion := cargoTOML.Package.RustVersion.(type) {
+				case string:
+					toolchain.Channel = rustVersion
+				case map[string]any:
+					// rust-version.workspace = true inherits from the workspace root
+					if inherit, _ := rustVersion["workspace"].(bool); inherit {
+						toolchain.Channel = findWorkspaceRustVersion(path, cargoTOML)
+					}
+				case nil:
+					if cargoTOML.Workspace != nil {
+						toolchain.Channel, _ = cargoTOML.Workspace.Package.RustVersion.(string)
+					}
+				}
+				if toolchain.Channel != "" {
+					log.Info("Detected Rust version via rust-version in Cargo.toml: " + toolchain.Channel)
+				}
+			}
+
+			f.Close()
+			if toolchain.Channel != "" {
+				break
+			}
+		}
+	}
+
+	if toolchain.Channel == "" {
+		toolchain.Channel = "stable"
+		log.Info(fmt.Sprintf("No Rust version detected. Using: %s", toolchain.Channel))
+	}
+
+	return &toolchain, nil
+}
+
+// Crates that link against system libraries which are only available for glibc.
+// openssl-sys is fine when it is vendored via openssl-src.
+var glibcCrates = []string{"openssl-sys", "pq-sys", "mysqlclient-sys", "libudev-sys", "glib-sys"}
+
+type cargoRustVersion struct {
+	Package struct {
+		// A version, or a table like {workspace = true}
+		RustVersion any `toml:"rust-version"`
+	} `toml:"package"`
+	Workspace *struct {
+		Package struct {
+			RustVersion any `toml:"rust-version"`
+		} `toml:"package"`
+	} `toml:"workspace"`
+}
+
+// Returns [workspace.package].rust-version from the workspace root, which is
+// the package itself or the nearest parent directory with a [workspace] table
+func findWorkspaceRustVersion(path string, cargoTOML cargoRustVersion) string {
+	dir, err := filepath.Abs(path)
+	if err != nil {
+		return ""
+	}
+
+	for {
+		if cargoTOML.Workspace != nil {
+			rustVersion, _ := cargoTOML.Workspace.Package.RustVersion.(string)
+			return rustVersion
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+
+		contents, err := os.ReadFile(filepath.Join(dir, "Cargo.toml"))
+		if err != nil {
+			continue
+		}
+
+		cargoTOML = cargoRustVersion{}
+		if err := toml.Unmarshal(contents, &cargoTOML); err != nil {
+			return ""
+		}
+	}
+}
+
+func findGlibcCrate(path string) (string, error) {
+	f, err := os.Open(filepath.Join(path, "Cargo.lock"))
+	if err != nil {
+		return "", nil
+	}
+
+	defer f.Close()
+
+	crates := map[string]bool{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "name = ") {
+			crates[strings.Trim(strings.TrimPrefix(line, "name = "), `"`)] = true
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return "", fmt.Errorf("Failed to read Cargo.lock")
+	}
+
+	for _, crate := range glibcCrates {
+		if crate == "openssl-sys" && crates["openssl-src"] {
+			continue
+		}
+
+		if crates[crate] {
+			return crate, nil
+		}
+	}
+
+	return "", nil
+}
@@ -24,6 +24,21 @@ func TestRustMatch(t *testing.T) {
 			path:     "../testdata/rust-bin",
 			expected: true,
 		},
+		{
+			name:     "Rust project with rust-toolchain.toml",
+			path:     "../testdata/rust-toolchain",
+			expected: true,
+		},
+		{
+			name:     "Rust project with rust-version",
+			path:     "../testdata/rust-msrv",
+			expected: true,
+		},
+		{
+			name:     "Rust project with openssl-sys",
+			path:     "../testdata/rust-openssl",
+			expected: true,
+		},
 		{
 			name:     "Not a Rust project",
 			path:     "../testdata/deno",
@@ -45,18 +60,51 @@ func TestRustGenerateDockerfile(t *testing.T) {
 	tests := []struct {
 		name     string
 		path     string
+		data     map[string]string
 		expected []any
 	}{
 		{
 			name:     "Rust project",
 			path:     "../testdata/rust",
-			expected: []any{`ARG BIN_NAME=ingest`},
+			expected: []any{`ARG VERSION=stable`, `ARG LIBC=gnu`, `FROM runtime-${LIBC} AS runtime`, `ARG BIN_NAME=ingest`, `RUN cargo chef prepare --recipe-path recipe.json`, `cargo chef cook --release --zigbuild`},
 		},
 		{
 			name:     "Rust project with [[bin]] directive",
 			path:     "../testdata/rust-bin",
 			expected: []any{`ARG BIN_NAME=rg`},
 		},
+		{
+			name: "Rust project with rust-toolchain.toml",
+			path: "../testdata/rust-toolchain",
+			expected: []any{
+				`ARG VERSION=1.78.0`,
+				`ARG RUST_COMPONENTS=clippy,rustfmt`,
+				`ARG RUST_TARGETS=wasm32-unknown-unknown`,
+				`ARG BIN_NAME=toolchain`,
+			},
+		},
+		{
+			name:     "Rust project with rust-version",
+			path:     "../testdata/rust-msrv",
+			expected: []any{`ARG VERSION=1.70`, regexp.MustCompile(`^ARG RUST_COMPONENTS=$`), `ARG BIN_NAME=msrv`},
+		},
+		{
+			name:     "Rust workspace member inheriting rust-version",
+			path:     "../testdata/rust-workspace/app",
+			expected: []any{`ARG VERSION=1.74`, `ARG BIN_NAME=app`},
+		},
+		{
+			name:     "Rust project with static musl build",
+			path:     "../testdata/rust",
+			data:     map[string]string{"Libc": "musl"},
+			expected: []any{`ARG LIBC=musl`, `FROM ${STATIC_IMAGE} AS runtime-musl`},
+		},
+		{
+			name:     "Rust project requiring glibc",
+			path:     "../testdata/rust-openssl",
+			data:     map[string]string{"Libc": "musl"},
+			expected: []any{`ARG LIBC=gnu`, `openssl-sys requires glibc`},
+		},
 		{
 			name:     "Not a Rust project",
 			path:     "../testdata/deno",
@@ -67,7 +115,7 @@ func TestRustGenerateDockerfile(t *testing.T) {
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
 			rust := &runtime.Rust{Log: logger}
-			dockerfile, err := rust.GenerateDockerfile(test.path)
+			dockerfile, err := rust.GenerateDockerfile(test.path, test.data)
 			if err != nil {
 				t.Errorf("unexpected error: %v", err)
 			}
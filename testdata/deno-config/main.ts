@@ -0,0 +1,12 @@
+import { Hono } from "hono";
+
+const kv = await Deno.openKv();
+const app = new Hono();
+
+app.get("/", async (c) => {
+  const visits = await kv.get<number>(["visits"]);
+  await kv.set(["visits"], (visits.value ?? 0) + 1);
+  return c.text(`Hello World ${visits.value ?? 0}`);
+});
+
+Deno.serve({ port: Number(Deno.env.get("PORT") ?? 8000) }, app.fetch);
@@ -0,0 +1,3 @@
+import { parseArgs } from "jsr:@std/cli/parse-args";
+
+console.log(parseArgs(Deno.args));
@@ -0,0 +1,3 @@
+import { serveDir } from "jsr:@std/http/file-server";
+
+Deno.serve((req) => serveDir(req));
@@ -0,0 +1,5 @@
+import { App, staticFiles } from "fresh";
+
+export const app = new App()
+  .use(staticFiles())
+  .get("/", () => new Response("Hello World"));
@@ -0,0 +1,6 @@
+import {
+  Hono,
+  type Context,
+} from "jsr:@hono/hono@^4.6.0";
+
+export const routes = new Hono().get("/", (c: Context) => c.text("Hello World"));
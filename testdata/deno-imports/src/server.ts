@@ -0,0 +1,3 @@
+import { routes } from "./routes/index.ts";
+
+Deno.serve({ port: Number(Deno.env.get("PORT") ?? 8000) }, routes.fetch);
@@ -0,0 +1,3 @@
+import { greet } from "@acme/shared";
+
+Deno.serve({ port: Number(Deno.env.get("PORT") ?? 8000) }, () => new Response(greet("World")));
@@ -0,0 +1,3 @@
+defmodule Worker.Application do
+  use Application
+end
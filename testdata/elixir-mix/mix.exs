@@ -0,0 +1,17 @@
+defmodule Worker.MixProject do
+  use Mix.Project
+
+  def project do
+    [
+      app: :worker,
+      version: "0.1.0",
+      elixir: "~> 1.16",
+      start_permanent: Mix.env() == :prod,
+      deps: []
+    ]
+  end
+
+  def application do
+    [extra_applications: [:logger], mod: {Worker.Application, []}]
+  end
+end
@@ -0,0 +1,2 @@
+defmodule HelloWeb do
+end
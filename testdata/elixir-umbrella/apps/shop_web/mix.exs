@@ -0,0 +1,25 @@
+defmodule ShopWeb.MixProject do
+  use Mix.Project
+
+  def project do
+    [
+      app: :shop_web,
+      version: "0.1.0",
+      build_path: "../../_build",
+      config_path: "../../config/config.exs",
+      deps_path: "../../deps",
+      lockfile: "../../mix.lock",
+      elixir: "~> 1.15",
+      aliases: aliases(),
+      deps: deps()
+    ]
+  end
+
+  defp deps do
+    [{:phoenix, "~> 1.7.14"}, {:shop, in_umbrella: true}]
+  end
+
+  defp aliases do
+    ["assets.deploy": ["esbuild shop_web --minify", "phx.digest"]]
+  end
+end
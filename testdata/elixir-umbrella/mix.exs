@@ -0,0 +1,29 @@
+defmodule Store.Umbrella.MixProject do
+  use Mix.Project
+
+  def project do
+    [
+      apps_path: "apps",
+      version: "0.1.0",
+      start_permanent: Mix.env() == :prod,
+      deps: deps(),
+      releases: releases()
+    ]
+  end
+
+  defp deps do
+    []
+  end
+
+  defp releases do
+    [
+      store: [
+        applications: [shop: :permanent, shop_web: :permanent],
+        include_executables_for: [:unix]
+      ],
+      admin: [
+        applications: [shop: :permanent, notes: :permanent]
+      ]
+    ]
+  end
+end
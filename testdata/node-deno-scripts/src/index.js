@@ -0,0 +1,3 @@
+const http = require("http");
+
+http.createServer((req, res) => res.end("Hello World")).listen(process.env.PORT || 8080);
@@ -0,0 +1,3 @@
+<?php
+define('WP_USE_THEMES', true);
+require dirname(__DIR__) . '/web/wp/wp-blog-header.php';
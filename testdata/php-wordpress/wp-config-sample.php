@@ -0,0 +1,9 @@
+<?php
+define( 'DB_NAME', 'database_name_here' );
+define( 'DB_USER', 'username_here' );
+define( 'DB_PASSWORD', 'password_here' );
+define( 'DB_HOST', 'localhost' );
+
+$table_prefix = 'wp_';
+
+require_once ABSPATH . 'wp-settings.php';
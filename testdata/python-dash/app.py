@@ -0,0 +1,7 @@
+from dash import Dash, html
+
+app = Dash(__name__)
+app.layout = html.Div("Hello, Dash!")
+
+if __name__ == "__main__":
+    app.run()
@@ -0,0 +1,6 @@
+import dramatiq
+
+
+@dramatiq.actor
+def send_email(address):
+    print(f"Sending email to {address}")
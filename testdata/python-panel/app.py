@@ -0,0 +1,3 @@
+import panel as pn
+
+pn.panel("Hello, Panel!").servable()
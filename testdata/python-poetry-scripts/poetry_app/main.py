@@ -0,0 +1,7 @@
+import os
+
+import uvicorn
+
+
+def run():
+    uvicorn.run("poetry_app.api:app", host="0.0.0.0", port=int(os.environ["PORT"]))
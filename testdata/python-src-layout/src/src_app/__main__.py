@@ -0,0 +1 @@
+print("Hello from src-app")
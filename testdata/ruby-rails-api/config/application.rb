@@ -0,0 +1,11 @@
+require_relative "boot"
+
+require "rails"
+require "action_controller/railtie"
+
+module Api
+  class Application < Rails::Application
+    config.load_defaults 7.1
+    config.api_only = true
+  end
+end